@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Attachment is a downloaded file kept by the AttachmentStore.
+type Attachment struct {
+	Data     []byte
+	MimeType string // sniffed from the content, not taken from Slack
+	Path     string // empty when kept in memory only
+}
+
+// AttachmentStore keeps downloaded attachments under content-addressed names, so a file name from Slack
+// never ends up in a path and the same file is stored once.
+type AttachmentStore struct {
+	dir       string
+	maxBytes  int64
+	retention time.Duration
+	inMemory  bool
+}
+
+const (
+	defaultAttachmentDir       = "./data/attachments"
+	defaultAttachmentMaxMB     = 10
+	defaultAttachmentRetention = 7 * 24 * time.Hour
+	attachmentCleanupInterval  = time.Hour
+)
+
+// attachmentExtensions are the sniffed types the store accepts.
+var attachmentExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+}
+
+func newAttachmentStore(cfg *Config) *AttachmentStore {
+	return &AttachmentStore{
+		dir:       cfg.AttachmentDir,
+		maxBytes:  int64(cfg.AttachmentMaxMB) << 20,
+		retention: cfg.AttachmentRetention,
+		inMemory:  cfg.AttachmentInMemory,
+	}
+}
+
+// MaxBytes is the largest attachment the store accepts, checked before downloading as well.
+func (s *AttachmentStore) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Save checks the size and the sniffed type of data and writes it to disk unless the store is in memory only.
+func (s *AttachmentStore) Save(data []byte) (Attachment, error) {
+	if int64(len(data)) > s.maxBytes {
+		return Attachment{}, fmt.Errorf("attachment of %d bytes is over the limit of %d", len(data), s.maxBytes)
+	}
+	mimeType := http.DetectContentType(data)
+	ext, ok := attachmentExtensions[mimeType]
+	if !ok {
+		return Attachment{}, fmt.Errorf("attachment type %s is not allowed", mimeType)
+	}
+
+	attachment := Attachment{Data: data, MimeType: mimeType}
+	if s.inMemory {
+		return attachment, nil
+	}
+
+	sum := sha256.Sum256(data)
+	attachment.Path = filepath.Join(s.dir, hex.EncodeToString(sum[:])+ext)
+	if _, err := os.Stat(attachment.Path); err == nil {
+		// Already stored; touch it so the retention starts again.
+		now := time.Now()
+		return attachment, os.Chtimes(attachment.Path, now, now)
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return Attachment{}, err
+	}
+	tmpPath := attachment.Path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return Attachment{}, err
+	}
+	return attachment, os.Rename(tmpPath, attachment.Path)
+}
+
+// Cleanup deletes the attachments older than the retention.
+func (s *AttachmentStore) Cleanup(now time.Time) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to list attachments", "err", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || now.Sub(entry.ModTime()) < s.retention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+			slog.Error("Failed to delete attachment", "file", entry.Name(), "err", err)
+		}
+	}
+}
+
+// watchAttachments applies the retention every hour.
+func watchAttachments(store *AttachmentStore) {
+	if store.inMemory {
+		return
+	}
+	ticker := time.NewTicker(attachmentCleanupInterval)
+	defer ticker.Stop()
+	for {
+		store.Cleanup(time.Now())
+		<-ticker.C
+	}
+}
+
+// limitedBuffer collects a download and fails once it grows over max bytes.
+type limitedBuffer struct {
+	data []byte
+	max  int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(len(b.data)+len(p)) > b.max {
+		return 0, fmt.Errorf("download is over the limit of %d bytes", b.max)
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
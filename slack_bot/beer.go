@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// beerSample is what the estimate is based on: a session's features and, for past sessions, how many beers were drunk.
+type beerSample struct {
+	Participants float64
+	Food         float64
+	Friday       float64
+	Hours        float64
+	Beers        float64
+}
+
+const (
+	defaultBeersPerPerson = 2.0
+	beerRidge             = 0.1
+)
+
+var beerFeatureNames = []string{"base", "participants", "food items", "Friday", "hours of grilling"}
+
+func (s beerSample) features() []float64 {
+	return []float64{1, s.Participants, s.Food, s.Friday, s.Hours}
+}
+
+func (b *Bot) handleBeer(cmd slack.SlashCommand) {
+	args := strings.Fields(cmd.Text)
+	if len(args) > 0 && args[0] == "record" {
+		b.handleBeerRecord(cmd, args[1:])
+		return
+	}
+
+	sessions, err := b.loadSessions()
+	if err != nil {
+		b.replyError(cmd, "Failed to load the session history.", err)
+		return
+	}
+	records, err := b.grill.GrillRecords()
+	if err != nil {
+		// The order window is used instead of the grill duration, so this isn't fatal.
+		slog.Error("Failed to fetch grill records", "err", err)
+	}
+
+	history := beerHistory(sessions, records)
+	upcoming := b.upcomingBeerSample(history)
+
+	if len(args) > 0 {
+		participants, err := strconv.Atoi(args[0])
+		if err != nil || participants < 1 {
+			b.replyEphemeral(cmd, "Usage: `/beer [participants] [hours]` or `/beer record {count}`")
+			return
+		}
+		upcoming.Food = upcoming.Food / math.Max(upcoming.Participants, 1) * float64(participants)
+		upcoming.Participants = float64(participants)
+	}
+	if len(args) > 1 {
+		hours, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || hours <= 0 {
+			b.replyEphemeral(cmd, "Invalid number of hours.")
+			return
+		}
+		upcoming.Hours = hours
+	}
+
+	postMessage(b.poster, cmd.ChannelID, estimateBeers(history, upcoming))
+}
+
+// handleBeerRecord stores how many beers the last session actually needed, which is what the estimate learns from.
+func (b *Bot) handleBeerRecord(cmd slack.SlashCommand, args []string) {
+	if len(args) < 1 {
+		b.replyEphemeral(cmd, "Please specify how many beers were drunk: `/beer record {count}`")
+		return
+	}
+	count, err := strconv.Atoi(args[0])
+	if err != nil || count < 0 {
+		b.replyEphemeral(cmd, "Invalid number of beers.")
+		return
+	}
+
+	var recorded *SessionRecord
+	err = b.updateSessions(func(sessions []SessionRecord) []SessionRecord {
+		if len(sessions) > 0 {
+			sessions[len(sessions)-1].Beers = &count
+			recorded = &sessions[len(sessions)-1]
+		}
+		return sessions
+	})
+	if err != nil {
+		b.replyError(cmd, "Failed to save the beer count.", err)
+		return
+	}
+	if recorded == nil {
+		postMessage(b.poster, cmd.ChannelID, "There is no finished session to record beers for yet.")
+		return
+	}
+
+	postMessage(b.poster, cmd.ChannelID, fmt.Sprintf("Recorded %d beers for the session from %s. Cheers! :beers:",
+		count, recorded.Started.Format("Mon 02 Jan")))
+}
+
+func beerHistory(sessions []SessionRecord, records []GrillRecord) []beerSample {
+	var samples []beerSample
+	for _, session := range sessions {
+		participants := len(session.Participants())
+		if participants == 0 {
+			continue
+		}
+
+		hours := session.Deadline.Sub(session.Started).Hours()
+		if record, ok := grillRecordForSession(session, records); ok {
+			hours = record.End.Sub(record.Start).Hours()
+		}
+
+		sample := beerSample{
+			Participants: float64(participants),
+			Food:         float64(session.TotalQuantity()),
+			Friday:       fridayFlag(session.Deadline),
+			Hours:        hours,
+			Beers:        -1,
+		}
+		if session.Beers != nil {
+			sample.Beers = float64(*session.Beers)
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// upcomingBeerSample describes the open session, or an average past session when none is open.
+func (b *Bot) upcomingBeerSample(history []beerSample) beerSample {
+	var average beerSample
+	for _, sample := range history {
+		average.Participants += sample.Participants
+		average.Food += sample.Food
+		average.Hours += sample.Hours
+	}
+	if len(history) > 0 {
+		average.Participants /= float64(len(history))
+		average.Food /= float64(len(history))
+		average.Hours /= float64(len(history))
+	} else {
+		average.Hours = 1
+	}
+	average.Participants = math.Round(average.Participants)
+	average.Friday = fridayFlag(b.clock.Now())
+
+	session, ok := b.session.snapshot()
+	if !ok || len(session.Orders) == 0 {
+		return average
+	}
+
+	average.Participants = float64(len(session.Participants()))
+	average.Food = float64(session.TotalQuantity())
+	average.Friday = fridayFlag(session.Deadline)
+	return average
+}
+
+func fridayFlag(t time.Time) float64 {
+	if t.Weekday() == time.Friday {
+		return 1
+	}
+	return 0
+}
+
+func estimateBeers(history []beerSample, upcoming beerSample) string {
+	var labeled []beerSample
+	for _, sample := range history {
+		if sample.Beers >= 0 {
+			labeled = append(labeled, sample)
+		}
+	}
+
+	header := fmt.Sprintf("For %.0f people eating %.0f items over %.1f hours", upcoming.Participants, upcoming.Food, upcoming.Hours)
+	if upcoming.Friday == 1 {
+		header += " on a Friday"
+	}
+
+	// A regression needs a few more sessions than it has coefficients to be worth anything.
+	if len(labeled) >= len(beerFeatureNames)+2 {
+		if coefficients, ok := fitBeerModel(labeled); ok {
+			features := upcoming.features()
+			estimate := 0.0
+			var parts []string
+			for i, coefficient := range coefficients {
+				contribution := coefficient * features[i]
+				estimate += contribution
+				if i == 0 {
+					parts = append(parts, fmt.Sprintf("%.1f base", coefficient))
+				} else {
+					parts = append(parts, fmt.Sprintf("%.2f × %.1f %s = %.1f", coefficient, features[i], beerFeatureNames[i], contribution))
+				}
+			}
+			beers := int(math.Ceil(math.Max(estimate, 0)))
+			return fmt.Sprintf("%s, buy about *%d beers* :beers:\nBased on %d past sessions: %s.",
+				header, beers, len(labeled), strings.Join(parts, " + "))
+		}
+	}
+
+	perPerson := defaultBeersPerPerson
+	basis := fmt.Sprintf("the default of %.1f beers per person", perPerson)
+	if len(labeled) > 0 {
+		var beers, people float64
+		for _, sample := range labeled {
+			beers += sample.Beers
+			people += sample.Participants
+		}
+		perPerson = beers / people
+		basis = fmt.Sprintf("%.1f beers per person over %d past sessions", perPerson, len(labeled))
+	}
+	beers := int(math.Ceil(perPerson * upcoming.Participants))
+	return fmt.Sprintf("%s, buy about *%d beers* :beers:\nBased on %s. Record what was actually drunk with `/beer record {count}` to improve the estimate.",
+		header, beers, basis)
+}
+
+// fitBeerModel solves a ridge regression of beers on the session features with the normal equations.
+func fitBeerModel(samples []beerSample) ([]float64, bool) {
+	n := len(beerFeatureNames)
+	matrix := make([][]float64, n)
+	for i := range matrix {
+		matrix[i] = make([]float64, n+1)
+	}
+
+	for _, sample := range samples {
+		features := sample.features()
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				matrix[i][j] += features[i] * features[j]
+			}
+			matrix[i][n] += features[i] * sample.Beers
+		}
+	}
+	// Don't penalize the intercept.
+	for i := 1; i < n; i++ {
+		matrix[i][i] += beerRidge
+	}
+
+	return solveLinearSystem(matrix)
+}
+
+// solveLinearSystem runs Gauss-Jordan elimination with partial pivoting on an augmented n×(n+1) matrix.
+func solveLinearSystem(matrix [][]float64) ([]float64, bool) {
+	n := len(matrix)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(matrix[row][col]) > math.Abs(matrix[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(matrix[pivot][col]) < 1e-9 {
+			return nil, false
+		}
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			}
+			factor := matrix[row][col] / matrix[col][col]
+			for k := col; k <= n; k++ {
+				matrix[row][k] -= factor * matrix[col][k]
+			}
+		}
+	}
+
+	solution := make([]float64, n)
+	for i := range solution {
+		solution[i] = matrix[i][n] / matrix[i][i]
+	}
+	return solution, true
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFitBeerModel(t *testing.T) {
+	t.Parallel()
+	// linear drinks 1 beer plus 2 per person, 0.1 per item, 3 more on a Friday and 0.5 per hour.
+	linear := func(participants, food, friday, hours float64) beerSample {
+		return beerSample{Participants: participants, Food: food, Friday: friday, Hours: hours,
+			Beers: 1 + 2*participants + 0.1*food + 3*friday + 0.5*hours}
+	}
+
+	tests := []struct {
+		name    string
+		samples []beerSample
+		ok      bool
+		// predict is checked against the fitted model, within tolerance beers.
+		predict   beerSample
+		tolerance float64
+	}{
+		{name: "no sessions"},
+		{
+			name: "linear history",
+			samples: []beerSample{
+				linear(4, 30, 0, 2), linear(6, 50, 1, 3), linear(8, 60, 0, 2.5), linear(5, 35, 1, 1.5),
+				linear(10, 90, 1, 4), linear(3, 20, 0, 1), linear(7, 40, 0, 3.5), linear(9, 80, 1, 2),
+			},
+			ok:        true,
+			predict:   linear(6, 45, 1, 2),
+			tolerance: 0.5,
+		},
+		{
+			name:      "identical sessions",
+			samples:   []beerSample{linear(5, 40, 0, 2), linear(5, 40, 0, 2), linear(5, 40, 0, 2)},
+			ok:        true,
+			predict:   linear(5, 40, 0, 2),
+			tolerance: 0.01,
+		},
+	}
+	for _, test := range tests {
+		coefficients, ok := fitBeerModel(test.samples)
+		if ok != test.ok {
+			t.Errorf("%s: ok = %v", test.name, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		estimate := 0.0
+		for i, feature := range test.predict.features() {
+			estimate += coefficients[i] * feature
+		}
+		if math.Abs(estimate-test.predict.Beers) > test.tolerance {
+			t.Errorf("%s: estimated %.2f beers, want %.2f (coefficients %v)", test.name, estimate, test.predict.Beers, coefficients)
+		}
+	}
+}
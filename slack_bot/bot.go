@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bot is what the handlers work with: Slack, the Bubble backend, the clock and the state of the running
+// bot. main wires one to the real services, tests build one per test with fakes.
+type Bot struct {
+	// settings is the config in effect, replaced whenever the config file changes.
+	settings    *atomic.Pointer[Config]
+	poster      MessagePoster
+	files       FileClient
+	dialogs     DialogOpener
+	menu        MenuRepository
+	orders      OrderRepository
+	grill       GrillRepository
+	clock       Clock
+	describer   ImageDescriber
+	attachments *AttachmentStore
+
+	// session is the open order session.
+	session orderSession
+
+	// shuttingDown is set on SIGINT/SIGTERM, from then on new commands are turned away.
+	shuttingDown atomic.Bool
+	// inFlight counts the events being handled and the work the watchers and the telemetry server started,
+	// shutdown waits for them.
+	inFlight sync.WaitGroup
+	// inFlightMu makes checking shuttingDown and adding to inFlight one step, so nothing is added once
+	// shutdown has started waiting.
+	inFlightMu sync.Mutex
+
+	// grillMu guards what was last heard about the grill.
+	grillMu          sync.Mutex
+	grillActive      bool
+	grillStatusKnown bool
+	grillOnSince     time.Time
+
+	// gasAlertActive is only used by watchGasLevel, so the alert is posted once per low level.
+	gasAlertActive bool
+
+	pendingReceiptsMu sync.Mutex
+	// pendingReceipts waits for the Confirm button, by correlation ID of the /receipt command.
+	pendingReceipts map[string]pendingReceipt
+
+	// botUserMu guards botUserID, which stays empty until AuthTest succeeds.
+	botUserMu sync.Mutex
+	botUserID string
+}
+
+// newBot wires a bot to Slack and Bubble on the backend's clock.
+func newBot(settings *atomic.Pointer[Config], client SlackClient, backend *bubbleBackend, describer ImageDescriber, attachments *AttachmentStore) *Bot {
+	return &Bot{
+		settings:    settings,
+		poster:      client,
+		files:       client,
+		dialogs:     client,
+		menu:        backend,
+		orders:      backend,
+		grill:       backend,
+		clock:       backend.clock,
+		describer:   describer,
+		attachments: attachments,
+	}
+}
+
+// config is the config in effect. Read it when it's needed instead of keeping values around, so changes
+// to the config file apply without a restart.
+func (b *Bot) config() *Config {
+	return b.settings.Load()
+}
@@ -0,0 +1,688 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// bubbleBackend is the Bubble data API the menu, the orders and the grill records are kept in. It
+// implements MenuRepository, OrderRepository and GrillRepository.
+type bubbleBackend struct {
+	settings *atomic.Pointer[Config]
+	clock    Clock
+	client   *http.Client
+}
+
+// newBubbleBackend sends its requests through a client that records them in the metrics.
+func newBubbleBackend(settings *atomic.Pointer[Config], clock Clock) *bubbleBackend {
+	return &bubbleBackend{
+		settings: settings,
+		clock:    clock,
+		client:   &http.Client{Transport: instrumentedTransport{next: http.DefaultTransport, settings: settings}},
+	}
+}
+
+func (backend *bubbleBackend) config() *Config {
+	return backend.settings.Load()
+}
+
+// AddItem creates a menu item from the fields given to /menu add.
+func (backend *bubbleBackend) AddItem(item map[string]interface{}) error {
+	url := backend.config().ServerItem
+	if url == "" {
+		return fmt.Errorf("SERVER_ITEM environment variable is not set")
+	}
+	itemJSON, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("error marshaling item: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(itemJSON))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := backend.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("error response from server: %v", resp.Status)
+	}
+	return nil
+}
+
+// ForwardReading relays a finished grill session to SERVER_GRILL, if it is set.
+func (backend *bubbleBackend) ForwardReading(reading GrillReading) error {
+	if url := backend.config().ServerGrill; url != "" {
+		return backend.forward(url, reading)
+	}
+	return nil
+}
+
+// ForwardStatus relays the grill status to the SERVER_GRILL_STATUS_WF workflow, if it is set.
+func (backend *bubbleBackend) ForwardStatus(reading GrillStatusReading) error {
+	if url := backend.config().ServerGrillStatusWF; url != "" {
+		return backend.forward(url, reading)
+	}
+	return nil
+}
+
+// Items fetches the menu from SERVER_ITEM.
+func (backend *bubbleBackend) Items() map[string]ItemInfo {
+	url := backend.config().ServerItem
+	client := backend.client
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		slog.Error("Failed to create request", "err", err)
+		return nil
+	}
+
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("Failed to send request", "err", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Error response from server", "status", resp.Status)
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		slog.Error("Failed to read response body", "err", err)
+		return nil
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		slog.Error("Failed to parse response body", "err", err)
+		return nil
+	}
+
+	itemData := make(map[string]ItemInfo)
+	if response, ok := result["response"].(map[string]interface{}); ok {
+		if results, ok := response["results"].([]interface{}); ok {
+			for _, res := range results {
+				if record, ok := res.(map[string]interface{}); ok {
+					itemName, _ := record["item name"].(string)
+					secondsToCook, _ := record["seconds to cook"].(float64)
+					capacityOnGrill, _ := record["capacity on grill"].(float64)
+					storeSection, _ := record["store section"].(string)
+					itemData[itemName] = ItemInfo{
+						ItemName:        itemName,
+						SecondsToCook:   int(secondsToCook),
+						CapacityOnGrill: int(capacityOnGrill),
+						StoreSection:    storeSection,
+					}
+				}
+			}
+		}
+	}
+
+	return itemData
+}
+
+func (backend *bubbleBackend) ItemID(itemName string) string {
+	return backend.idFromServer(backend.config().ServerItem, itemName, "item name")
+}
+
+func (backend *bubbleBackend) userID(userName string) string {
+	return backend.idFromServer(backend.config().ServerUsers, userName, "name")
+}
+
+func (backend *bubbleBackend) idFromServer(url, searchValue, searchKey string) string {
+	client := backend.client
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		slog.Error("Failed to create request", "err", err)
+		return ""
+	}
+
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("Failed to send request", "err", err)
+		return ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Error response from server", "status", resp.Status)
+		return ""
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		slog.Error("Failed to read response body", "err", err)
+		return ""
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		slog.Error("Failed to parse response body", "err", err)
+		return ""
+	}
+
+	if response, ok := result["response"].(map[string]interface{}); ok {
+		if results, ok := response["results"].([]interface{}); ok {
+			for _, res := range results {
+				if record, ok := res.(map[string]interface{}); ok {
+					if name, ok := record[searchKey].(string); ok && name == searchValue {
+						if id, ok := record["_id"].(string); ok {
+							return id
+						}
+					}
+				}
+			}
+		}
+	}
+
+	slog.Warn("ID not found in response")
+	return ""
+}
+
+func (backend *bubbleBackend) SendOrderSummary(orderSummary map[string]interface{}) {
+	client := backend.client
+	url := backend.config().ServerOrder
+
+	orderSummaryJSON, err := json.Marshal(orderSummary)
+	if err != nil {
+		slog.Error("Failed to marshal order summary", "err", err)
+		return
+	}
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(string(orderSummaryJSON)))
+	if err != nil {
+		slog.Error("Failed to create request", "err", err)
+		return
+	}
+
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("Failed to send request", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Error response from server", "status", resp.Status)
+		return
+	}
+
+	slog.Info("Order summary sent")
+}
+
+// RecentOrders returns the IDs of the order summaries created in the last hour.
+func (backend *bubbleBackend) RecentOrders() []string {
+	client := backend.client
+	url := backend.config().ServerOrder
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		slog.Error("Failed to create request", "err", err)
+		return nil
+	}
+
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("Failed to send request", "err", err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Error response from server", "status", resp.Status)
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		slog.Error("Failed to read response body", "err", err)
+		return nil
+	}
+
+	var response struct {
+		Response struct {
+			Results []struct {
+				CreatedDate    string `json:"Created Date"`
+				ItemOrdered    string `json:"item ordered"`
+				SecondsToCook  int    `json:"seconds to cook"`
+				SummedQuantity int    `json:"summed quantity"`
+				ID             string `json:"_id"`
+			} `json:"results"`
+		} `json:"response"`
+	}
+
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		slog.Error("Failed to unmarshal response", "err", err)
+		return nil
+	}
+
+	oneHourAgo := backend.clock.Now().Add(-1 * time.Hour)
+	var recentOrders []string
+	for _, order := range response.Response.Results {
+		createdDate, err := time.Parse(time.RFC3339, order.CreatedDate)
+		if err != nil {
+			slog.Error("Failed to parse date", "err", err)
+			continue
+		}
+		if createdDate.After(oneHourAgo) {
+			recentOrders = append(recentOrders, order.ID)
+		}
+	}
+
+	return recentOrders
+}
+
+func (backend *bubbleBackend) SendRecentOrders(orders []string) {
+	if len(orders) == 0 {
+		slog.Info("No recent orders to send")
+		return
+	}
+
+	client := backend.client
+	url := backend.config().ServerFullOrder
+
+	orderData := map[string]interface{}{
+		"orders": orders,
+	}
+
+	orderDataJSON, err := json.Marshal(orderData)
+	if err != nil {
+		slog.Error("Failed to marshal order data", "err", err)
+		return
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(orderDataJSON))
+	if err != nil {
+		slog.Error("Failed to create request", "err", err)
+		return
+	}
+
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("Failed to send request", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Read and log the response body
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		slog.Error("Failed to read response body", "err", err)
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Error response from server", "status", resp.Status)
+		slog.Debug("Response body", "body", string(body))
+		return
+	}
+
+	slog.Info("Recent orders sent")
+}
+
+func (backend *bubbleBackend) sendOrder(order map[string]interface{}) {
+	client := backend.client
+	url := backend.config().ServerOrder
+
+	orderJSON, err := json.Marshal(order)
+	if err != nil {
+		slog.Error("Failed to marshal order", "err", err)
+		return
+	}
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(string(orderJSON)))
+	if err != nil {
+		slog.Error("Failed to create request", "err", err)
+		return
+	}
+
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("Failed to send request", "err", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("Error response from server", "status", resp.Status)
+		return
+	}
+
+	slog.Info("Order sent")
+}
+
+// Summaries returns the order summaries from SERVER_ORDER with their item names, oldest first.
+func (backend *bubbleBackend) Summaries() ([]OrderSummaryRecord, error) {
+	url := backend.config().ServerOrder
+	if url == "" {
+		return nil, fmt.Errorf("SERVER_ORDER environment variable is not set")
+	}
+
+	results, err := backend.records(url)
+	if err != nil {
+		return nil, err
+	}
+	itemNames, err := backend.itemNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []OrderSummaryRecord
+	for _, result := range results {
+		createdDate, _ := result["Created Date"].(string)
+		created, err := parseBubbleTime(createdDate)
+		if err != nil {
+			slog.Error("Failed to parse date", "err", err)
+			continue
+		}
+		itemID, _ := result["item ordered"].(string)
+		quantity, _ := result["summed quantity"].(float64)
+		secondsToCook, _ := result["seconds to cook"].(float64)
+
+		name, ok := itemNames[itemID]
+		if !ok {
+			name = "unknown item"
+		}
+		summaries = append(summaries, OrderSummaryRecord{
+			Created:       created,
+			ItemID:        itemID,
+			Item:          name,
+			Quantity:      int(quantity),
+			SecondsToCook: int(secondsToCook),
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Created.Before(summaries[j].Created) })
+	return summaries, nil
+}
+
+// itemNames maps menu item IDs to their names.
+func (backend *bubbleBackend) itemNames() (map[string]string, error) {
+	results, err := backend.records(backend.config().ServerItem)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	for _, result := range results {
+		id, _ := result["_id"].(string)
+		name, _ := result["item name"].(string)
+		if id != "" {
+			names[id] = name
+		}
+	}
+	return names, nil
+}
+
+// SetSecondsToCook changes how long the named item cooks.
+func (backend *bubbleBackend) SetSecondsToCook(item string, seconds int) error {
+	itemID := backend.ItemID(item)
+	if itemID == "" {
+		return fmt.Errorf("item %s not found", item)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{"seconds to cook": seconds})
+	if err != nil {
+		return fmt.Errorf("error marshaling payload: %w", err)
+	}
+
+	req, err := http.NewRequest("PATCH", backend.config().ServerItem+"/"+itemID, strings.NewReader(string(payload)))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := backend.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("error response from server: %v", resp.Status)
+	}
+	return nil
+}
+
+// Ready asks Bubble for one menu item, the cheapest request every command depends on.
+func (backend *bubbleBackend) Ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", backend.config().ServerItem, nil)
+	if err != nil {
+		return err
+	}
+	query := req.URL.Query()
+	query.Set("limit", "1")
+	req.URL.RawQuery = query.Encode()
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+
+	resp, err := backend.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error response from server: %v", resp.Status)
+	}
+	return nil
+}
+
+// GrillRecords reads the grill sessions from Bubble, or from the readings the telemetry
+// server stored locally when the bot runs without Bubble.
+func (backend *bubbleBackend) GrillRecords() ([]GrillRecord, error) {
+	cfg := backend.config()
+	url := cfg.ServerGrill
+	if url == "" {
+		if cfg.TelemetryAddr == "" {
+			return nil, fmt.Errorf("SERVER_GRILL environment variable is not set")
+		}
+		records, err := loadLocalGrillRecords(cfg)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return records, err
+	}
+
+	results, err := backend.records(url)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []GrillRecord
+	for _, result := range results {
+		if record, ok := grillRecordFromMap(result); ok {
+			records = append(records, record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].End.Before(records[j].End) })
+	return records, nil
+}
+
+func grillRecordFromMap(record map[string]interface{}) (GrillRecord, bool) {
+	startGas, ok1 := record["grill start gas"].(float64)
+	endGas, ok2 := record["grill end gas"].(float64)
+	startTime, _ := record["start time"].(string)
+	endTime, _ := record["end time"].(string)
+	if !ok1 || !ok2 {
+		return GrillRecord{}, false
+	}
+
+	start, err := parseBubbleTime(startTime)
+	if err != nil {
+		slog.Warn("Failed to parse grill start time", "value", startTime, "err", err)
+		return GrillRecord{}, false
+	}
+	end, err := parseBubbleTime(endTime)
+	if err != nil {
+		slog.Warn("Failed to parse grill end time", "value", endTime, "err", err)
+		return GrillRecord{}, false
+	}
+
+	averageConsumption, _ := record["average_consumption"].(float64)
+	return GrillRecord{
+		StartGas:           startGas,
+		EndGas:             endGas,
+		AverageConsumption: averageConsumption,
+		Start:              start,
+		End:                end,
+	}, true
+}
+
+// BottleWeight is GAS_BOTTLE_WEIGHT, or the weight of the gas bottle record at SERVER_GAS_BOTTLE that the
+// scale subtracts as well.
+func (backend *bubbleBackend) BottleWeight() (float64, error) {
+	cfg := backend.config()
+	if cfg.GasBottleWeight > 0 {
+		return cfg.GasBottleWeight * gramsPerKg, nil
+	}
+	if cfg.ServerGasBottle == "" {
+		return 0, fmt.Errorf("neither GAS_BOTTLE_WEIGHT nor SERVER_GAS_BOTTLE is set")
+	}
+
+	records, err := backend.records(cfg.ServerGasBottle)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, fmt.Errorf("no gas bottle record found")
+	}
+	weight, ok := records[0]["weight"].(float64)
+	if !ok || weight < 0 {
+		return 0, fmt.Errorf("gas bottle record has no valid weight field")
+	}
+	return weight * gramsPerKg, nil
+}
+
+// GrillActive reads whether the grill is on from the Grill_Status record at SERVER_GRILL_STATUS.
+func (backend *bubbleBackend) GrillActive() (bool, error) {
+	records, err := backend.records(backend.config().ServerGrillStatus)
+	if err != nil {
+		return false, err
+	}
+	if len(records) == 0 {
+		return false, fmt.Errorf("no grill status record found")
+	}
+
+	active, ok := records[0]["grill_active"].(bool)
+	if !ok {
+		return false, fmt.Errorf("grill status record has no grill_active field")
+	}
+	return active, nil
+}
+
+func (backend *bubbleBackend) forward(url string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling payload: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadJSON))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := backend.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("error response from server: %v", resp.Status)
+	}
+	return nil
+}
+
+// records pages through a Bubble data API list endpoint and returns every record.
+func (backend *bubbleBackend) records(url string) ([]map[string]interface{}, error) {
+	client := backend.client
+	var records []map[string]interface{}
+	cursor := 0
+
+	for {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s?cursor=%d", url, cursor), nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		req.Header.Add("Authorization", "Bearer "+backend.config().BearerToken)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error sending request: %w", err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading response body: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error response from server: %v", resp.Status)
+		}
+
+		var page struct {
+			Response struct {
+				Cursor    int                      `json:"cursor"`
+				Results   []map[string]interface{} `json:"results"`
+				Remaining int                      `json:"remaining"`
+			} `json:"response"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("error unmarshaling response: %w", err)
+		}
+
+		records = append(records, page.Response.Results...)
+		if page.Response.Remaining <= 0 || len(page.Response.Results) == 0 {
+			return records, nil
+		}
+		cursor = page.Response.Cursor + len(page.Response.Results)
+	}
+}
+
+// parseBubbleTime accepts both the ISO dates Bubble returns and the "YYYY-MM-DD HH:MM:SS" format the scale sends.
+// The scale formats its NTP time without a timezone, so the latter is UTC.
+func parseBubbleTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", value, time.UTC)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+type ChartPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+const (
+	chartWidth        = 800
+	chartHeight       = 400
+	chartMarginLeft   = 60
+	chartMarginRight  = 20
+	chartMarginTop    = 40
+	chartMarginBottom = 50
+	chartTicks        = 5
+)
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartAxis       = color.RGBA{60, 60, 60, 255}
+	chartGrid       = color.RGBA{225, 225, 225, 255}
+	chartPalette    = []color.RGBA{
+		{230, 97, 1, 255},
+		{94, 60, 153, 255},
+		{27, 158, 119, 255},
+		{217, 95, 2, 255},
+		{117, 112, 179, 255},
+		{231, 41, 138, 255},
+		{102, 166, 30, 255},
+		{166, 118, 29, 255},
+	}
+)
+
+type chartCanvas struct {
+	img  *image.RGBA
+	maxY float64
+}
+
+func newChartCanvas(title, yLabel string, maxY float64) *chartCanvas {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+
+	if maxY <= 0 {
+		maxY = 1
+	}
+	canvas := &chartCanvas{img: img, maxY: niceCeil(maxY)}
+
+	canvas.text(chartMarginLeft, 20, title, chartAxis)
+	canvas.text(4, chartMarginTop-8, yLabel, chartAxis)
+
+	// Horizontal grid with value ticks
+	for i := 0; i <= chartTicks; i++ {
+		value := canvas.maxY * float64(i) / chartTicks
+		y := canvas.y(value)
+		canvas.line(chartMarginLeft, y, chartWidth-chartMarginRight, y, chartGrid)
+		canvas.text(4, y+4, formatTick(value), chartAxis)
+	}
+	canvas.line(chartMarginLeft, chartMarginTop, chartMarginLeft, chartHeight-chartMarginBottom, chartAxis)
+	canvas.line(chartMarginLeft, chartHeight-chartMarginBottom, chartWidth-chartMarginRight, chartHeight-chartMarginBottom, chartAxis)
+
+	return canvas
+}
+
+// renderLineChart plots values over time, e.g. the gas left in the bottle.
+func renderLineChart(title, yLabel string, points []ChartPoint) ([]byte, error) {
+	maxY := 0.0
+	for _, point := range points {
+		maxY = math.Max(maxY, point.Value)
+	}
+	canvas := newChartCanvas(title, yLabel, maxY)
+
+	if len(points) == 0 {
+		canvas.text(chartWidth/2-40, chartHeight/2, "No data", chartAxis)
+		return canvas.png()
+	}
+
+	first, last := points[0].Time, points[len(points)-1].Time
+	span := last.Sub(first).Seconds()
+	x := func(t time.Time) int {
+		if span == 0 {
+			return (chartMarginLeft + chartWidth - chartMarginRight) / 2
+		}
+		return chartMarginLeft + int(t.Sub(first).Seconds()/span*float64(plotWidth()))
+	}
+
+	for i := 0; i <= chartTicks; i++ {
+		t := first.Add(time.Duration(float64(last.Sub(first)) * float64(i) / chartTicks))
+		canvas.text(minInt(x(t)-18, chartWidth-45), chartHeight-chartMarginBottom+16, t.Format("02 Jan"), chartAxis)
+	}
+
+	lineColor := chartPalette[0]
+	for i := 1; i < len(points); i++ {
+		canvas.thickLine(x(points[i-1].Time), canvas.y(points[i-1].Value), x(points[i].Time), canvas.y(points[i].Value), lineColor)
+	}
+	for _, point := range points {
+		canvas.dot(x(point.Time), canvas.y(point.Value), lineColor)
+	}
+
+	return canvas.png()
+}
+
+// renderBarChart draws one bar per label. Each bar is stacked from the series values,
+// with a legend when there is more than one series.
+func renderBarChart(title, yLabel string, labels []string, series []string, values [][]float64) ([]byte, error) {
+	maxY := 0.0
+	for _, stack := range values {
+		total := 0.0
+		for _, value := range stack {
+			total += value
+		}
+		maxY = math.Max(maxY, total)
+	}
+	canvas := newChartCanvas(title, yLabel, maxY)
+
+	if len(labels) == 0 {
+		canvas.text(chartWidth/2-40, chartHeight/2, "No data", chartAxis)
+		return canvas.png()
+	}
+
+	slot := plotWidth() / len(labels)
+	barWidth := slot * 2 / 3
+	if barWidth < 1 {
+		barWidth = 1
+	}
+	// Skip labels when they would overlap.
+	labelEvery := 1 + 50/maxInt(slot, 1)
+
+	for i, stack := range values {
+		left := chartMarginLeft + i*slot + (slot-barWidth)/2
+		base := 0.0
+		for s, value := range stack {
+			if value <= 0 {
+				continue
+			}
+			top := canvas.y(base + value)
+			bottom := canvas.y(base)
+			draw.Draw(canvas.img, image.Rect(left, top, left+barWidth, bottom),
+				&image.Uniform{chartPalette[s%len(chartPalette)]}, image.Point{}, draw.Src)
+			base += value
+		}
+		if i%labelEvery == 0 {
+			canvas.text(left, chartHeight-chartMarginBottom+16, labels[i], chartAxis)
+		}
+	}
+
+	if len(series) > 1 {
+		x := chartMarginLeft
+		for s, name := range series {
+			if x > chartWidth-chartMarginRight-60 {
+				break
+			}
+			draw.Draw(canvas.img, image.Rect(x, chartHeight-18, x+10, chartHeight-8),
+				&image.Uniform{chartPalette[s%len(chartPalette)]}, image.Point{}, draw.Src)
+			canvas.text(x+14, chartHeight-8, name, chartAxis)
+			x += 24 + 7*len(name)
+		}
+	}
+
+	return canvas.png()
+}
+
+func (c *chartCanvas) y(value float64) int {
+	plotHeight := chartHeight - chartMarginTop - chartMarginBottom
+	return chartHeight - chartMarginBottom - int(value/c.maxY*float64(plotHeight))
+}
+
+func (c *chartCanvas) text(x, y int, label string, col color.Color) {
+	drawer := font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(col),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(label)
+}
+
+// line draws a one pixel line with Bresenham's algorithm.
+func (c *chartCanvas) line(x0, y0, x1, y1 int, col color.Color) {
+	dx := absInt(x1 - x0)
+	dy := -absInt(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		c.img.Set(x0, y0, col)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func (c *chartCanvas) thickLine(x0, y0, x1, y1 int, col color.Color) {
+	c.line(x0, y0, x1, y1, col)
+	c.line(x0, y0+1, x1, y1+1, col)
+	c.line(x0+1, y0, x1+1, y1, col)
+}
+
+func (c *chartCanvas) dot(x, y int, col color.Color) {
+	draw.Draw(c.img, image.Rect(x-2, y-2, x+3, y+3), &image.Uniform{col}, image.Point{}, draw.Src)
+}
+
+func (c *chartCanvas) png() ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, c.img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func plotWidth() int {
+	return chartWidth - chartMarginLeft - chartMarginRight
+}
+
+// niceCeil rounds the axis maximum up to 1, 2 or 5 times a power of ten.
+func niceCeil(value float64) float64 {
+	exponent := math.Pow(10, math.Floor(math.Log10(value)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if value <= step*exponent {
+			return step * exponent
+		}
+	}
+	return 10 * exponent
+}
+
+func formatTick(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.2f", value)
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
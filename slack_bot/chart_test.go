@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestRenderCharts(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		render func() ([]byte, error)
+		plots  bool // whether anything is drawn in the first series colour
+	}{
+		{"line without points", func() ([]byte, error) { return renderLineChart("Gas", "kg", nil) }, false},
+		{"line with one point", func() ([]byte, error) {
+			return renderLineChart("Gas", "kg", []ChartPoint{{Time: day, Value: 4.5}})
+		}, true},
+		{"line with one empty point", func() ([]byte, error) {
+			return renderLineChart("Gas", "kg", []ChartPoint{{Time: day, Value: 0}})
+		}, true},
+		{"line at the same time", func() ([]byte, error) {
+			return renderLineChart("Gas", "kg", []ChartPoint{{Time: day, Value: 5}, {Time: day, Value: 4}})
+		}, true},
+		{"line over a week", func() ([]byte, error) {
+			return renderLineChart("Gas", "kg", []ChartPoint{{Time: day, Value: 5}, {Time: day.AddDate(0, 0, 7), Value: 2.25}})
+		}, true},
+		{"bars without labels", func() ([]byte, error) { return renderBarChart("Items", "pcs", nil, nil, nil) }, false},
+		{"one bar", func() ([]byte, error) {
+			return renderBarChart("Items", "pcs", []string{"07 Jun"}, nil, [][]float64{{12}})
+		}, true},
+		{"zero bars", func() ([]byte, error) {
+			return renderBarChart("Items", "pcs", []string{"07 Jun", "14 Jun"}, nil, [][]float64{{0}, {0}})
+		}, false},
+		{"more bars than pixels", func() ([]byte, error) {
+			labels := make([]string, 1000)
+			values := make([][]float64, len(labels))
+			for i := range labels {
+				labels[i], values[i] = "x", []float64{float64(i), 1}
+			}
+			return renderBarChart("Items", "pcs", labels, []string{"kebapche", "kufte"}, values)
+		}, true},
+	}
+	for _, test := range tests {
+		data, err := test.render()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: not a PNG: %v", test.name, err)
+			continue
+		}
+		if bounds := img.Bounds(); bounds != image.Rect(0, 0, chartWidth, chartHeight) {
+			t.Errorf("%s: bounds %v", test.name, bounds)
+		}
+		if plots := hasColor(img, chartPalette[0]); plots != test.plots {
+			t.Errorf("%s: plotted %v, want %v", test.name, plots, test.plots)
+		}
+	}
+}
+
+// hasColor reports whether any pixel of img is want.
+func hasColor(img image.Image, want color.Color) bool {
+	r, g, b, a := want.RGBA()
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if pr, pg, pb, pa := img.At(x, y).RGBA(); pr == r && pg == g && pb == b && pa == a {
+				return true
+			}
+		}
+	}
+	return false
+}
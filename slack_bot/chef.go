@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// ChefStats is one line of the Master Chef leaderboard.
+type ChefStats struct {
+	Chef         string
+	Sessions     int
+	CookSeconds  int
+	GasGrams     float64
+	ItemsCooked  int
+	RatingSum    int
+	RatingCount  int
+	GrillRecords int
+}
+
+const (
+	// A chef can still be claimed or rated this long after the session closed.
+	chefClaimWindow = 12 * time.Hour
+	minRatingsBadge = 3
+)
+
+// GramsPerItem is the gas burned per item cooked, lower is better. Zero when there is no grill data.
+func (c ChefStats) GramsPerItem() float64 {
+	if c.GrillRecords == 0 || c.ItemsCooked == 0 {
+		return 0
+	}
+	return c.GasGrams / float64(c.ItemsCooked)
+}
+
+func (c ChefStats) AverageRating() float64 {
+	if c.RatingCount == 0 {
+		return 0
+	}
+	return float64(c.RatingSum) / float64(c.RatingCount)
+}
+
+func (b *Bot) handleChef(cmd slack.SlashCommand) {
+	args := strings.Fields(cmd.Text)
+	if len(args) > 0 && args[0] == "rate" {
+		b.handleChefRate(cmd, args[1:])
+		return
+	}
+
+	if b.session.setChef(cmd.UserID) {
+		postMessage(b.poster, cmd.ChannelID, fmt.Sprintf("<@%s> is on the grill for this session :cook:", cmd.UserID))
+		return
+	}
+
+	// The grill usually gets going after the order deadline, so allow claiming the session that just closed.
+	var claimed bool
+	err := b.updateSessions(func(sessions []SessionRecord) []SessionRecord {
+		if len(sessions) > 0 && b.clock.Now().Sub(sessions[len(sessions)-1].Closed) < chefClaimWindow {
+			sessions[len(sessions)-1].Chef = cmd.UserID
+			claimed = true
+		}
+		return sessions
+	})
+	if err != nil {
+		b.replyError(cmd, "Failed to save the chef.", err)
+		return
+	}
+	if !claimed {
+		b.replyEphemeral(cmd, "There is no session to claim. Start one with /start {time}.")
+		return
+	}
+
+	postMessage(b.poster, cmd.ChannelID, fmt.Sprintf("<@%s> is on the grill for the last session :cook:", cmd.UserID))
+}
+
+func (b *Bot) handleChefRate(cmd slack.SlashCommand, args []string) {
+	if len(args) < 1 {
+		b.replyEphemeral(cmd, "Please specify a rating from 1 to 5: `/chef rate {1-5}`")
+		return
+	}
+	rating, err := strconv.Atoi(args[0])
+	if err != nil || rating < 1 || rating > 5 {
+		b.replyEphemeral(cmd, "The rating must be a number from 1 to 5.")
+		return
+	}
+
+	message, err := b.rateLastChef(cmd.UserID, rating)
+	if err != nil {
+		b.replyError(cmd, "Failed to save the rating.", err)
+		return
+	}
+	postMessage(b.poster, cmd.ChannelID, message)
+}
+
+// rateLastChef stores userID's rating of the last session's chef and returns the reply to show.
+func (b *Bot) rateLastChef(userID string, rating int) (string, error) {
+	var message string
+	err := b.updateSessions(func(sessions []SessionRecord) []SessionRecord {
+		if len(sessions) == 0 || b.clock.Now().Sub(sessions[len(sessions)-1].Closed) > chefClaimWindow {
+			message = "There is no recent session to rate."
+			return sessions
+		}
+
+		session := &sessions[len(sessions)-1]
+		switch {
+		case session.Chef == "":
+			message = "Nobody claimed the grill for the last session."
+		case session.Chef == userID:
+			message = "Nice try, but chefs can't rate themselves :wink:"
+		default:
+			if session.Ratings == nil {
+				session.Ratings = make(map[string]int)
+			}
+			session.Ratings[userID] = rating
+			message = fmt.Sprintf("Thanks! You rated <@%s> %s", session.Chef, strings.Repeat(":star:", rating))
+		}
+		return sessions
+	})
+	return message, err
+}
+
+func (b *Bot) handleLeaderboard(cmd slack.SlashCommand) {
+	period := "all"
+	if args := strings.Fields(cmd.Text); len(args) > 0 {
+		period = args[0]
+	}
+
+	var since time.Time
+	if period != "all" {
+		var ok bool
+		since, ok = historySince(period, b.clock.Now())
+		if !ok {
+			b.replyEphemeral(cmd, "Usage: `/leaderboard [week|month|all]`")
+			return
+		}
+	}
+
+	sessions, err := b.loadSessions()
+	if err != nil {
+		b.replyError(cmd, "Failed to load the session history.", err)
+		return
+	}
+	records, err := b.grill.GrillRecords()
+	if err != nil {
+		// The ranking still works on sessions and ratings without the scale.
+		slog.Error("Failed to fetch grill records", "err", err)
+	}
+
+	stats := chefLeaderboard(sessions, records, since)
+	if len(stats) == 0 {
+		postMessage(b.poster, cmd.ChannelID, "No chef has grilled in this period yet. Claim the grill with /chef.")
+		return
+	}
+
+	postMessage(b.poster, cmd.ChannelID, formatLeaderboard(stats, period))
+}
+
+// chefLeaderboard aggregates the sessions closed after since per chef, ranked by sessions grilled
+// and then by average rating.
+func chefLeaderboard(sessions []SessionRecord, records []GrillRecord, since time.Time) []ChefStats {
+	byChef := make(map[string]*ChefStats)
+	for _, session := range sessions {
+		if session.Chef == "" || session.Closed.Before(since) {
+			continue
+		}
+		stats, ok := byChef[session.Chef]
+		if !ok {
+			stats = &ChefStats{Chef: session.Chef}
+			byChef[session.Chef] = stats
+		}
+
+		stats.Sessions++
+		for _, rating := range session.Ratings {
+			stats.RatingSum += rating
+			stats.RatingCount++
+		}
+		if record, ok := grillRecordForSession(session, records); ok {
+			stats.GrillRecords++
+			stats.CookSeconds += int(record.End.Sub(record.Start).Seconds())
+			stats.GasGrams += record.StartGas - record.EndGas
+			stats.ItemsCooked += session.TotalQuantity()
+		}
+	}
+
+	var leaderboard []ChefStats
+	for _, stats := range byChef {
+		leaderboard = append(leaderboard, *stats)
+	}
+	sort.Slice(leaderboard, func(i, j int) bool {
+		if leaderboard[i].Sessions != leaderboard[j].Sessions {
+			return leaderboard[i].Sessions > leaderboard[j].Sessions
+		}
+		if leaderboard[i].AverageRating() != leaderboard[j].AverageRating() {
+			return leaderboard[i].AverageRating() > leaderboard[j].AverageRating()
+		}
+		return leaderboard[i].Chef < leaderboard[j].Chef
+	})
+	return leaderboard
+}
+
+// chefBadges hands out one badge per category to the best chef in it.
+func chefBadges(leaderboard []ChefStats) map[string][]string {
+	badges := make(map[string][]string)
+	if len(leaderboard) == 0 {
+		return badges
+	}
+	badges[leaderboard[0].Chef] = append(badges[leaderboard[0].Chef], ":crown: Master Chef")
+
+	longest, efficient, topRated := -1, -1, -1
+	for i, stats := range leaderboard {
+		if stats.CookSeconds > 0 && (longest < 0 || stats.CookSeconds > leaderboard[longest].CookSeconds) {
+			longest = i
+		}
+		if stats.GramsPerItem() > 0 && (efficient < 0 || stats.GramsPerItem() < leaderboard[efficient].GramsPerItem()) {
+			efficient = i
+		}
+		if stats.RatingCount >= minRatingsBadge && (topRated < 0 || stats.AverageRating() > leaderboard[topRated].AverageRating()) {
+			topRated = i
+		}
+	}
+	if longest >= 0 {
+		badges[leaderboard[longest].Chef] = append(badges[leaderboard[longest].Chef], ":fire: Grill Master")
+	}
+	if efficient >= 0 {
+		badges[leaderboard[efficient].Chef] = append(badges[leaderboard[efficient].Chef], ":fuelpump: Gas Saver")
+	}
+	if topRated >= 0 {
+		badges[leaderboard[topRated].Chef] = append(badges[leaderboard[topRated].Chef], ":star2: Crowd Favourite")
+	}
+	return badges
+}
+
+func formatLeaderboard(leaderboard []ChefStats, period string) string {
+	title := "all time"
+	if period != "all" {
+		title = "the last " + period
+	}
+	badges := chefBadges(leaderboard)
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Master Chef leaderboard for %s :trophy:\n", title))
+	for i, stats := range leaderboard {
+		line := fmt.Sprintf("%d. <@%s> — %d sessions", i+1, stats.Chef, stats.Sessions)
+		if stats.CookSeconds > 0 {
+			line += fmt.Sprintf(", %s on the grill", formatSeconds(stats.CookSeconds))
+		}
+		if perItem := stats.GramsPerItem(); perItem > 0 {
+			line += fmt.Sprintf(", %.0f g of gas per item", perItem)
+		}
+		if stats.RatingCount > 0 {
+			line += fmt.Sprintf(", rated %.1f/5 (%d votes)", math.Round(stats.AverageRating()*10)/10, stats.RatingCount)
+		}
+		if chefBadge := badges[stats.Chef]; len(chefBadge) > 0 {
+			line += " " + strings.Join(chefBadge, " ")
+		}
+		builder.WriteString(line + "\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,20 @@
+package main
+
+import "time"
+
+// Clock tells the time for order sessions and their deadlines.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the wall clock, tests use a fake one to run a session without waiting for its deadline.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Command is a slash command. handleSlashCommand checks the role and the arguments against it before
+// calling Handler, and /help is generated from it.
+type Command struct {
+	Name        string
+	Args        []Arg
+	Role        Role
+	Description string
+	// Details is shown by /help {command} only.
+	Details     string
+	Subcommands []Subcommand
+	Handler     func(*Bot, slack.SlashCommand)
+}
+
+// Subcommand is a first word (or words, e.g. "holiday add") with its own arguments and role.
+// The command's Handler still runs it.
+type Subcommand struct {
+	Name        string
+	Args        []Arg
+	Role        Role
+	Description string
+}
+
+type ArgType int
+
+const (
+	argText ArgType = iota
+	argInt
+	argNumber
+	argTime
+	argDate
+	argUser
+)
+
+// Arg is one argument of a command. Optional arguments must come last.
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+	Rest     bool // takes all the remaining words
+	Choices  []string
+}
+
+var periodChoices = []string{"week", "month"}
+
+var commands []Command
+
+func init() {
+	// The registry is filled here because /help refers back to it.
+	commands = []Command{
+		{
+			Name:        "/hi",
+			Description: "Check that the bot is running.",
+			Handler:     (*Bot).handleHi,
+		},
+		{
+			Name:        "/start",
+			Args:        []Arg{{Name: "time", Type: argTime}, {Name: "suggest", Optional: true, Choices: []string{"suggest"}}},
+			Role:        roleChef,
+			Description: "Start a new order session. No orders are accepted after the deadline {time} (HH:MM).",
+			Details:     "Add `suggest` to get a shopping recommendation based on the last sessions. A reminder is posted before the deadline (5 minutes by default), then the orders are summarized.",
+			Handler:     (*Bot).handleStart,
+		},
+		{
+			Name:        "/order",
+			Args:        []Arg{{Name: "item", Type: argText}, {Name: "quantity", Type: argInt}},
+			Description: "Order {quantity} of {item} from the menu in the open session.",
+			Handler:     (*Bot).handleOrder,
+		},
+		{
+			Name:        "/menu",
+			Description: "Show the menu.",
+			Subcommands: []Subcommand{{
+				Name: "add",
+				Args: []Arg{
+					{Name: "item", Type: argText},
+					{Name: "capacity_on_grill", Type: argInt},
+					{Name: "price", Type: argNumber},
+					{Name: "seconds_to_cook", Type: argInt},
+					{Name: "store section", Optional: true, Rest: true},
+				},
+				Role:        roleAdmin,
+				Description: "Add an item to the menu.",
+			}},
+			Details: "{capacity_on_grill} is how many of the item fit on the grill at the same time and {seconds_to_cook} is roughly how long it cooks. " +
+				"The store section, e.g. `Meat`, groups the item on the shopping list.",
+			Handler: (*Bot).handleMenu,
+		},
+		{
+			Name:        "/receipt",
+			Args:        []Arg{{Name: "link", Optional: true}},
+			Description: "Read the items and total from the latest receipt in the channel and add it to the session's expenses.",
+			Details: "Pass a file link, a file ID or a message link to read a specific receipt; a message link also searches its thread. " +
+				"Photos (PNG, JPEG, WebP, HEIC) and PDFs are supported.",
+			Handler: (*Bot).handleReceipt,
+		},
+		{
+			Name:        "/split",
+			Description: "Split the receipts of the current or last session between the people who ordered.",
+			Details: "Receipt items that match an ordered menu item are split by how many each person ordered, " +
+				"everything else is split evenly.",
+			Handler: (*Bot).handleSplit,
+		},
+		{
+			Name:        "/shopping-list",
+			Description: "Get the shopping list for the current session's orders, grouped by store section.",
+			Handler:     (*Bot).handleShoppingList,
+		},
+		{
+			Name:        "/gas",
+			Description: "See how much gas is left in the bottle and when it is expected to run out.",
+			Handler:     (*Bot).handleGas,
+		},
+		{
+			Name:        "/history",
+			Args:        []Arg{{Name: "period", Optional: true, Choices: periodChoices}},
+			Description: "See the grill sessions, gas used and most ordered items, with a CSV export.",
+			Subcommands: []Subcommand{{
+				Name:        "chart",
+				Args:        []Arg{{Name: "period", Optional: true, Choices: periodChoices}},
+				Description: "Get the same history as charts.",
+			}},
+			Handler: (*Bot).handleHistory,
+		},
+		{
+			Name:        "/beer",
+			Args:        []Arg{{Name: "participants", Type: argInt, Optional: true}, {Name: "hours", Type: argNumber, Optional: true}},
+			Description: "Estimate how many beers to buy.",
+			Subcommands: []Subcommand{{
+				Name:        "record",
+				Args:        []Arg{{Name: "count", Type: argInt}},
+				Description: "Tell the bot how many beers were drunk in the last session.",
+			}},
+			Handler: (*Bot).handleBeer,
+		},
+		{
+			Name:        "/chef",
+			Description: "Claim the grill for the current session.",
+			Subcommands: []Subcommand{{
+				Name:        "rate",
+				Args:        []Arg{{Name: "1-5", Type: argInt}},
+				Description: "Rate the last session's chef.",
+			}},
+			Handler: (*Bot).handleChef,
+		},
+		{
+			Name:        "/leaderboard",
+			Args:        []Arg{{Name: "period", Optional: true, Choices: []string{"week", "month", "all"}}},
+			Description: "See the Master Chef ranking.",
+			Handler:     (*Bot).handleLeaderboard,
+		},
+		{
+			Name:        "/feedback",
+			Args:        []Arg{{Name: "period", Optional: true, Choices: []string{"week", "month", "all"}}},
+			Description: "See the food ratings and doneness votes from the surveys.",
+			Handler:     (*Bot).handleFeedback,
+		},
+		{
+			Name:        "/tune",
+			Description: "Propose new cooking times from the actual grill timings and the feedback.",
+			Subcommands: []Subcommand{{
+				Name:        "apply",
+				Role:        roleAdmin,
+				Description: "Apply the proposed cooking times.",
+			}},
+			Handler: (*Bot).handleTune,
+		},
+		{
+			Name:        "/schedule",
+			Description: "Manage the sessions the bot opens automatically.",
+			Subcommands: []Subcommand{
+				{Name: "add", Args: []Arg{{Name: "rule", Rest: true}}, Role: roleAdmin,
+					Description: "Add a recurring session, e.g. `/schedule add \"Fri 12:30 deadline 12:00\"`."},
+				{Name: "list", Description: "Show the schedules and their next session."},
+				{Name: "remove", Args: []Arg{{Name: "id", Type: argInt}}, Role: roleAdmin, Description: "Delete a schedule."},
+				{Name: "skip", Args: []Arg{{Name: "id", Type: argInt}, {Name: "date", Type: argDate, Optional: true}}, Role: roleAdmin,
+					Description: "Skip the next (or the given) session of a schedule."},
+				{Name: "holiday add", Args: []Arg{{Name: "date", Type: argDate}}, Role: roleAdmin, Description: "Open no scheduled session on a day."},
+				{Name: "holiday remove", Args: []Arg{{Name: "date", Type: argDate}}, Role: roleAdmin, Description: "Remove a holiday."},
+				{Name: "holiday list", Description: "Show the holidays."},
+			},
+			Details: "Days can be a single day (`Fri`), a list (`Mon,Wed,Fri`), a range (`Mon-Fri`) or `daily`. " +
+				"Add `open HH:MM` to the rule to choose when the session opens.",
+			Handler: (*Bot).handleSchedule,
+		},
+		{
+			Name:        "/role",
+			Description: "See your role.",
+			Subcommands: []Subcommand{
+				{Name: "list", Description: "Show everyone's role."},
+				{Name: "grant", Args: []Arg{{Name: "user", Type: argUser}, {Name: "role", Choices: []string{"admin", "chef", "member"}}},
+					Role: roleAdmin, Description: "Give a user a role."},
+				{Name: "revoke", Args: []Arg{{Name: "user", Type: argUser}}, Role: roleAdmin, Description: "Make a user a member again."},
+				{Name: "audit", Args: []Arg{{Name: "count", Type: argInt, Optional: true}}, Role: roleAdmin,
+					Description: "Show the latest privileged actions."},
+			},
+			Handler: (*Bot).handleRole,
+		},
+		{
+			Name:        "/help",
+			Args:        []Arg{{Name: "command", Optional: true}},
+			Description: "Show this help, or the details of one command.",
+			Handler:     (*Bot).handleHelp,
+		},
+	}
+}
+
+func findCommand(name string) (Command, bool) {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	for _, command := range commands {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return Command{}, false
+}
+
+// findSubcommand matches the first words of args against the subcommands.
+func (c Command) findSubcommand(args []string) (Subcommand, bool) {
+	for _, sub := range c.Subcommands {
+		words := strings.Fields(sub.Name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == sub.Name {
+			return sub, true
+		}
+	}
+	return Subcommand{}, false
+}
+
+func commandNames() []string {
+	var names []string
+	for _, command := range commands {
+		names = append(names, command.Name)
+	}
+	return names
+}
+
+// subcommandNames are the first words of the subcommands, e.g. "holiday" for "holiday add".
+func (c Command) subcommandNames() []string {
+	var names []string
+	for _, sub := range c.Subcommands {
+		name, _, _ := strings.Cut(sub.Name, " ")
+		if !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// invocation is what a command's arguments are checked against once the subcommand is known.
+type invocation struct {
+	role       Role
+	schema     []Arg
+	usage      string
+	args       []string // without the subcommand words
+	subcommand bool
+}
+
+func (c Command) invocation(args []string) invocation {
+	call := invocation{role: c.Role, schema: c.Args, usage: c.usage(), args: args}
+	if sub, ok := c.findSubcommand(args); ok {
+		if sub.Role != "" {
+			call.role = sub.Role
+		}
+		call.schema, call.usage = sub.Args, c.subcommandUsage(sub)
+		call.args = args[len(strings.Fields(sub.Name)):]
+		call.subcommand = true
+	}
+	return call
+}
+
+func (b *Bot) handleSlashCommand(cmd slack.SlashCommand) {
+	command, ok := findCommand(cmd.Command)
+	if !ok {
+		b.commandLogger(cmd).Info("Unknown command")
+		commandsHandled.WithLabelValues("unknown", "unknown").Inc()
+		b.replyEphemeral(cmd, fmt.Sprintf("I don't know %s.%s Type `/help` to see all commands.",
+			cmd.Command, didYouMean(cmd.Command, commandNames(), "%s")))
+		return
+	}
+	b.commandLogger(cmd).Info("Command received", "text", cmd.Text)
+
+	call := command.invocation(strings.Fields(cmd.Text))
+	if !b.authorize(cmd, call.role) {
+		commandsHandled.WithLabelValues(command.Name, "denied").Inc()
+		return
+	}
+	if err := validateArgs(call.schema, call.args); err != nil {
+		hint := ""
+		if !call.subcommand && len(call.args) > 0 && len(command.Subcommands) > 0 {
+			hint = didYouMean(call.args[0], command.subcommandNames(), command.Name+" %s")
+		}
+		b.replyEphemeral(cmd, fmt.Sprintf("%s%s Usage: `%s`, or see `/help %s`.", err, hint, call.usage, strings.TrimPrefix(command.Name, "/")))
+		commandsHandled.WithLabelValues(command.Name, "invalid").Inc()
+		return
+	}
+	countCommand(command.Name, cmd, func() { command.Handler(b, cmd) })
+}
+
+func validateArgs(schema []Arg, args []string) error {
+	for i, arg := range schema {
+		if i >= len(args) {
+			if arg.Optional {
+				return nil
+			}
+			return fmt.Errorf("Missing {%s}.", arg.Name)
+		}
+		if arg.Rest {
+			return nil
+		}
+		if err := arg.validate(args[i]); err != nil {
+			return err
+		}
+	}
+	if len(args) > len(schema) {
+		return fmt.Errorf("Unexpected %q.", strings.Join(args[len(schema):], " "))
+	}
+	return nil
+}
+
+func (a Arg) validate(value string) error {
+	if len(a.Choices) > 0 {
+		// Handlers compare choices exactly, so a different case is suggested instead of accepted.
+		for _, choice := range a.Choices {
+			if value == choice {
+				return nil
+			}
+		}
+		return fmt.Errorf("{%s} must be one of %s.%s", a.Name, strings.Join(a.Choices, ", "), didYouMean(value, a.Choices, "%s"))
+	}
+
+	var err error
+	switch a.Type {
+	case argInt:
+		_, err = strconv.Atoi(value)
+	case argNumber:
+		_, err = strconv.ParseFloat(value, 64)
+	case argTime:
+		_, err = time.Parse("15:04", value)
+	case argDate:
+		_, err = time.Parse("2006-01-02", value)
+	case argUser:
+		if _, ok := parseUserMention(value); !ok {
+			err = fmt.Errorf("not a user")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid {%s} %q, expected %s.", a.Name, value, a.Type)
+	}
+	return nil
+}
+
+func (t ArgType) String() string {
+	switch t {
+	case argInt:
+		return "a whole number"
+	case argNumber:
+		return "a number"
+	case argTime:
+		return "a time as HH:MM"
+	case argDate:
+		return "a date as YYYY-MM-DD"
+	case argUser:
+		return "a @mention"
+	}
+	return "text"
+}
+
+func (a Arg) usage() string {
+	name := a.Name
+	if len(a.Choices) > 0 {
+		name = strings.Join(a.Choices, "|")
+	}
+	if a.Rest {
+		name += "..."
+	}
+	if a.Optional {
+		return "[" + name + "]"
+	}
+	if len(a.Choices) == 1 {
+		return name
+	}
+	return "{" + name + "}"
+}
+
+func formatUsage(words []string, args []Arg) string {
+	for _, arg := range args {
+		words = append(words, arg.usage())
+	}
+	return strings.Join(words, " ")
+}
+
+func (c Command) usage() string {
+	return formatUsage([]string{c.Name}, c.Args)
+}
+
+func (c Command) subcommandUsage(sub Subcommand) string {
+	return formatUsage([]string{c.Name, sub.Name}, sub.Args)
+}
+
+func (b *Bot) handleHi(cmd slack.SlashCommand) {
+	postMessage(b.poster, cmd.ChannelID, "Hi, I'm Slack Bot. I got your command.")
+}
+
+func (b *Bot) handleHelp(cmd slack.SlashCommand) {
+	if args := strings.Fields(cmd.Text); len(args) > 0 {
+		command, ok := findCommand(args[0])
+		if !ok {
+			b.replyEphemeral(cmd, fmt.Sprintf("There is no command %s.%s Type `/help` to see all of them.",
+				args[0], didYouMean(args[0], commandNames(), "/help %s")))
+			return
+		}
+		postMessage(b.poster, cmd.ChannelID, commandHelp(command))
+		return
+	}
+
+	var builder strings.Builder
+	builder.WriteString("This is a Slack bot for managing grill orders. An order session is started with `/start`, " +
+		"everyone orders from the menu with `/order` until the deadline and the bot then sums up what to grill.\n")
+	for _, command := range commands {
+		builder.WriteString(helpLine(command.usage(), command.Description, command.Role))
+		for _, sub := range command.Subcommands {
+			builder.WriteString(helpLine(command.subcommandUsage(sub), sub.Description, sub.Role))
+		}
+	}
+	builder.WriteString("Type `/help {command}` for more details.")
+	postMessage(b.poster, cmd.ChannelID, builder.String())
+}
+
+func commandHelp(command Command) string {
+	var builder strings.Builder
+	builder.WriteString(helpLine(command.usage(), command.Description, command.Role))
+	for _, sub := range command.Subcommands {
+		builder.WriteString(helpLine(command.subcommandUsage(sub), sub.Description, sub.Role))
+	}
+	if command.Details != "" {
+		builder.WriteString(command.Details + "\n")
+	}
+	return builder.String()
+}
+
+func helpLine(usage, description string, role Role) string {
+	line := fmt.Sprintf("• `%s` %s", usage, description)
+	if role.rank() > roleMember.rank() {
+		line += fmt.Sprintf(" _(%s)_", role)
+	}
+	return line + "\n"
+}
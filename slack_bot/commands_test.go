@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateArgsChoicesMatchExactly(t *testing.T) {
+	t.Parallel()
+	start, _ := findCommand("/start")
+	history, _ := findCommand("/history")
+	tests := []struct {
+		command Command
+		text    string
+		err     string
+	}{
+		{start, "12:30 suggest", ""},
+		{start, "12:30 SUGGEST", "{suggest} must be one of suggest. Did you mean `suggest`?"},
+		{history, "month", ""},
+		{history, "Month", "{period} must be one of week, month. Did you mean `month`?"},
+		{history, "chart month", ""},
+		{history, "chart WEEK", "{period} must be one of week, month. Did you mean `week`?"},
+	}
+	for _, test := range tests {
+		call := test.command.invocation(strings.Fields(test.text))
+		got := ""
+		if err := validateArgs(call.schema, call.args); err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%s %s: got %q, want %q", test.command.Name, test.text, got, test.err)
+		}
+	}
+}
+
+func TestMixedCaseChoiceIsNotRun(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.run(testChef, "/start 12:30 SUGGEST")
+	h.expectMessage("Did you mean `suggest`?")
+	if _, ok := h.bot.session.snapshot(); ok {
+		t.Fatal("the session must not start without the suggestions that were asked for")
+	}
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/joho/godotenv"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// Config is everything the bot reads from the environment, .env and the config file. Each field is
+// set by the upper-case environment variable of its key, e.g. server_item by SERVER_ITEM.
+type Config struct {
+	SlackAppToken string `mapstructure:"slack_app_token"`
+	SlackBotToken string `mapstructure:"slack_bot_token"`
+	ChannelID     string `mapstructure:"channel_id"`
+	// Timezone is an IANA name like Europe/Sofia for deadlines, schedules and the digest, the server's own when empty.
+	Timezone  string `mapstructure:"timezone"`
+	location  *time.Location
+	LogLevel  string `mapstructure:"log_level"`
+	LogFormat string `mapstructure:"log_format"`
+	// MetricsAddr serves /healthz, /readyz and /metrics, turned off when empty.
+	MetricsAddr     string        `mapstructure:"metrics_addr"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	// Bubble
+	BearerToken         string `mapstructure:"bearer_token"`
+	ServerItem          string `mapstructure:"server_item"`
+	ServerOrder         string `mapstructure:"server_order"`
+	ServerFullOrder     string `mapstructure:"server_full_order"`
+	ServerUsers         string `mapstructure:"server_users"`
+	ServerGrill         string `mapstructure:"server_grill"`
+	ServerGrillStatus   string `mapstructure:"server_grill_status"`
+	ServerGrillStatusWF string `mapstructure:"server_grill_status_wf"`
+	ServerGasBottle     string `mapstructure:"server_gas_bottle"`
+
+	// Grill and gas
+	GrillStatusInterval time.Duration `mapstructure:"grill_status_interval"`
+	GasAlertThreshold   float64       `mapstructure:"gas_alert_threshold"`
+	GasCheckInterval    time.Duration `mapstructure:"gas_check_interval"`
+	// GasBottleWeight is the empty bottle in kg, the scale weighs it with the gas. Read from SERVER_GAS_BOTTLE when 0.
+	GasBottleWeight float64 `mapstructure:"gas_bottle_weight"`
+	TelemetryAddr   string  `mapstructure:"telemetry_addr"`
+	TelemetryToken  string  `mapstructure:"telemetry_token"`
+	TelemetryDir    string  `mapstructure:"telemetry_dir"`
+
+	// Sessions
+	SessionStore         string        `mapstructure:"session_store"`
+	OpenSessionStore     string        `mapstructure:"open_session_store"`
+	ReminderOffset       time.Duration `mapstructure:"reminder_offset"`
+	StartRecommendations bool          `mapstructure:"start_recommendations"`
+	ShoppingMargin       float64       `mapstructure:"shopping_margin"`
+	FeedbackSurveys      bool          `mapstructure:"feedback_surveys"`
+	DigestDay            string        `mapstructure:"digest_day"`
+	DigestTime           string        `mapstructure:"digest_time"`
+	ScheduleStore        string        `mapstructure:"schedule_store"`
+	ScheduleOpenLead     time.Duration `mapstructure:"schedule_open_lead"`
+
+	// Roles
+	AdminUsers  []string `mapstructure:"admin_users"`
+	DefaultRole string   `mapstructure:"default_role"`
+	RoleStore   string   `mapstructure:"role_store"`
+	AuditLog    string   `mapstructure:"audit_log"`
+
+	// Receipts
+	ImageDescriber      string        `mapstructure:"image_describer"`
+	OpenAIAPIKey        string        `mapstructure:"openai_api_key"`
+	OpenAIBaseURL       string        `mapstructure:"openai_base_url"`
+	OpenAIModel         string        `mapstructure:"openai_model"`
+	ReceiptChannelID    string        `mapstructure:"receipt_channel_id"`
+	AttachmentDir       string        `mapstructure:"attachment_dir"`
+	AttachmentMaxMB     int           `mapstructure:"attachment_max_mb"`
+	AttachmentRetention time.Duration `mapstructure:"attachment_retention"`
+	AttachmentInMemory  bool          `mapstructure:"attachment_in_memory"`
+}
+
+var configDefaults = map[string]interface{}{
+	"log_level":             defaultLogLevel,
+	"log_format":            defaultLogFormat,
+	"metrics_addr":          defaultMetricsAddr,
+	"shutdown_timeout":      defaultShutdownTimeout,
+	"open_session_store":    defaultOpenSessionStore,
+	"grill_status_interval": defaultGrillStatusInterval,
+	"gas_alert_threshold":   defaultGasAlertThresholdKg,
+	"gas_check_interval":    defaultGasCheckInterval,
+	"telemetry_dir":         defaultTelemetryDir,
+	"session_store":         defaultSessionStore,
+	"reminder_offset":       defaultReminderOffset,
+	"shopping_margin":       defaultShoppingMargin,
+	"feedback_surveys":      true,
+	"digest_time":           defaultDigestTime,
+	"schedule_store":        defaultScheduleStore,
+	"schedule_open_lead":    defaultScheduleOpenLead,
+	"default_role":          string(roleMember),
+	"role_store":            defaultRoleStore,
+	"audit_log":             defaultAuditLog,
+	"openai_model":          defaultVisionModel,
+	"attachment_dir":        defaultAttachmentDir,
+	"attachment_max_mb":     defaultAttachmentMaxMB,
+	"attachment_retention":  defaultAttachmentRetention,
+}
+
+// restartConfig are the keys that are only read at startup, so reloading can't apply them.
+var restartConfig = []string{
+	"slack_app_token", "slack_bot_token", "telemetry_addr", "image_describer", "openai_api_key", "openai_base_url",
+	"openai_model", "attachment_dir", "attachment_max_mb", "attachment_retention", "attachment_in_memory", "log_format",
+	"metrics_addr",
+}
+
+const configReloadDelay = 500 * time.Millisecond
+
+// loadConfig reads the config file (CONFIG_FILE, or config.yaml/config.toml in the working directory if
+// there is one), then .env, then the environment, each overriding the one before, and validates the result.
+// It also returns the path of the config file, empty when there is none.
+func loadConfig() (*Config, string, error) {
+	// godotenv never overrides variables that are already set, and .env is optional when they are.
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("reading .env: %w", err)
+	}
+
+	v := viper.New()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.SetConfigName("config")
+		v.AddConfigPath(".")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, "", fmt.Errorf("reading config file: %w", err)
+		}
+	}
+
+	for key, value := range configDefaults {
+		v.SetDefault(key, value)
+	}
+	for _, key := range configKeys() {
+		if err := v.BindEnv(key, strings.ToUpper(key)); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// Values that don't decode are reported together with the rest of the problems.
+	var cfg Config
+	var problems []string
+	if err := v.Unmarshal(&cfg); err != nil {
+		var decodeErr *mapstructure.Error
+		if !errors.As(err, &decodeErr) {
+			return nil, "", fmt.Errorf("parsing config: %w", err)
+		}
+		problems = append(problems, decodeErr.Errors...)
+	}
+	cfg.normalize()
+	problems = append(problems, cfg.problems()...)
+	if len(problems) > 0 {
+		return nil, "", fmt.Errorf("invalid configuration:\n- %s", strings.Join(problems, "\n- "))
+	}
+	return &cfg, v.ConfigFileUsed(), nil
+}
+
+// configKeys lists the mapstructure keys of Config, so every field can be set from the environment.
+func configKeys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("mapstructure"); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (c *Config) normalize() {
+	var admins []string
+	for _, admin := range c.AdminUsers {
+		if admin = strings.TrimSpace(admin); admin != "" {
+			admins = append(admins, admin)
+		}
+	}
+	c.AdminUsers = admins
+	c.OpenAIBaseURL = strings.TrimSuffix(c.OpenAIBaseURL, "/")
+
+	c.location = time.Local
+	if c.Timezone != "" {
+		// Left nil when invalid, problems reports it.
+		c.location, _ = time.LoadLocation(c.Timezone)
+	}
+
+	if c.TelemetryToken == "" {
+		// The scale can use the same token it sends to Bubble.
+		c.TelemetryToken = c.BearerToken
+	}
+	if c.ImageDescriber == "" {
+		c.ImageDescriber = "local"
+		if c.OpenAIAPIKey != "" || c.OpenAIBaseURL != "" {
+			c.ImageDescriber = "openai"
+		}
+	}
+}
+
+// problems lists everything wrong with the config at once, so a new setup doesn't take one restart per missing key.
+func (c *Config) problems() []string {
+	var problems []string
+	// The bot can't answer any command without these.
+	for _, required := range []struct{ key, value string }{
+		{"SLACK_APP_TOKEN", c.SlackAppToken},
+		{"SLACK_BOT_TOKEN", c.SlackBotToken},
+		{"BEARER_TOKEN", c.BearerToken},
+		{"SERVER_ITEM", c.ServerItem},
+		{"SERVER_ORDER", c.ServerOrder},
+		{"SERVER_FULL_ORDER", c.ServerFullOrder},
+		{"SERVER_USERS", c.ServerUsers},
+	} {
+		if required.value == "" {
+			problems = append(problems, required.key+" is not set")
+		}
+	}
+
+	if c.SlackAppToken != "" && !strings.HasPrefix(c.SlackAppToken, "xapp-") {
+		problems = append(problems, "SLACK_APP_TOKEN must be an app-level token starting with xapp-")
+	}
+	if c.SlackBotToken != "" && !strings.HasPrefix(c.SlackBotToken, "xoxb-") {
+		problems = append(problems, "SLACK_BOT_TOKEN must be a bot token starting with xoxb-")
+	}
+	for _, interval := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"GRILL_STATUS_INTERVAL", c.GrillStatusInterval},
+		{"GAS_CHECK_INTERVAL", c.GasCheckInterval},
+		{"SCHEDULE_OPEN_LEAD", c.ScheduleOpenLead},
+		{"ATTACHMENT_RETENTION", c.AttachmentRetention},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+	} {
+		if interval.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be a positive duration, got %s", interval.key, interval.value))
+		}
+	}
+	if _, ok := parseLogLevel(c.LogLevel); !ok {
+		problems = append(problems, fmt.Sprintf("LOG_LEVEL %q must be debug, info, warn or error", c.LogLevel))
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		problems = append(problems, fmt.Sprintf("LOG_FORMAT %q must be text or json", c.LogFormat))
+	}
+	if c.ReminderOffset < 0 {
+		problems = append(problems, fmt.Sprintf("REMINDER_OFFSET must not be negative, got %s", c.ReminderOffset))
+	}
+	if c.location == nil {
+		problems = append(problems, fmt.Sprintf("TIMEZONE %q is not a known timezone", c.Timezone))
+	}
+	if c.GasBottleWeight < 0 {
+		problems = append(problems, fmt.Sprintf("GAS_BOTTLE_WEIGHT must not be negative, got %g", c.GasBottleWeight))
+	}
+	if c.ShoppingMargin < 0 {
+		problems = append(problems, fmt.Sprintf("SHOPPING_MARGIN must not be negative, got %g", c.ShoppingMargin))
+	}
+	if c.AttachmentMaxMB <= 0 {
+		problems = append(problems, fmt.Sprintf("ATTACHMENT_MAX_MB must be positive, got %d", c.AttachmentMaxMB))
+	}
+	if c.DigestDay != "" {
+		if _, ok := parseWeekday(c.DigestDay); !ok {
+			problems = append(problems, fmt.Sprintf("DIGEST_DAY %q is not a weekday", c.DigestDay))
+		}
+	}
+	if _, err := time.Parse("15:04", c.DigestTime); err != nil {
+		problems = append(problems, fmt.Sprintf("DIGEST_TIME %q is not HH:MM", c.DigestTime))
+	}
+	if _, ok := parseRole(c.DefaultRole); !ok {
+		problems = append(problems, fmt.Sprintf("DEFAULT_ROLE %q must be member, chef or admin", c.DefaultRole))
+	}
+	switch c.ImageDescriber {
+	case "local":
+	case "openai":
+		if c.OpenAIAPIKey == "" && c.OpenAIBaseURL == "" {
+			problems = append(problems, "IMAGE_DESCRIBER openai needs OPENAI_API_KEY or OPENAI_BASE_URL")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("IMAGE_DESCRIBER %q must be openai or local", c.ImageDescriber))
+	}
+
+	return problems
+}
+
+// isAdminUser reports whether userID is one of the ADMIN_USERS.
+func (c *Config) isAdminUser(userID string) bool {
+	return userID != "" && containsString(c.AdminUsers, userID)
+}
+
+// Location is the timezone of deadlines, schedules and the digest.
+func (c *Config) Location() *time.Location {
+	return c.location
+}
+
+// localNow is the current time in the configured timezone.
+func (b *Bot) localNow() time.Time {
+	return b.clock.Now().In(b.config().Location())
+}
+
+// watchConfig reloads settings when the config file changes. An invalid change is logged and ignored,
+// so the bot keeps running with the previous config.
+func watchConfig(path string, settings *atomic.Pointer[Config]) {
+	if path == "" {
+		return
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		slog.Error("Failed to watch the config file", "err", err)
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Error("Failed to watch the config file", "err", err)
+		return
+	}
+	defer watcher.Close()
+
+	// The directory is watched because editors often save by replacing the file.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		slog.Error("Failed to watch the config file", "err", err)
+		return
+	}
+	slog.Info("Watching the config file for changes", "path", path)
+
+	// Saving fires several events, the reload waits until they stop.
+	var reload <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == path && event.Has(fsnotify.Write|fsnotify.Create) {
+				reload = time.After(configReloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("Config file watcher failed", "err", err)
+		case <-reload:
+			reload = nil
+			reloadConfig(settings)
+		}
+	}
+}
+
+func reloadConfig(settings *atomic.Pointer[Config]) {
+	cfg, _, err := loadConfig()
+	if err != nil {
+		slog.Error("Ignoring the config file change, keeping the previous configuration", "err", err)
+		return
+	}
+	previous := settings.Load()
+	changes, restart := diffConfig(previous, cfg)
+	if len(changes) == 0 {
+		return
+	}
+	keepRestartConfig(previous, cfg)
+	settings.Store(cfg)
+	applyLogLevel(cfg)
+	slog.Info("Configuration reloaded", "changes", strings.Join(changes, ", "))
+	if len(restart) > 0 {
+		slog.Warn("Restart the bot to apply some changes", "keys", strings.Join(restart, ", "))
+	}
+}
+
+// diffConfig describes the changed keys, without the values of secrets, and lists the changed keys
+// that need a restart.
+func diffConfig(previous, next *Config) ([]string, []string) {
+	var changes, restart []string
+	before, after := reflect.ValueOf(*previous), reflect.ValueOf(*next)
+	for i := 0; i < before.NumField(); i++ {
+		key := before.Type().Field(i).Tag.Get("mapstructure")
+		if key == "" || reflect.DeepEqual(before.Field(i).Interface(), after.Field(i).Interface()) {
+			continue
+		}
+		if strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_key") {
+			changes = append(changes, key+" changed")
+		} else {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", key, formatConfigValue(before.Field(i)), formatConfigValue(after.Field(i))))
+		}
+		if containsString(restartConfig, key) {
+			restart = append(restart, key)
+		}
+	}
+	return changes, restart
+}
+
+// keepRestartConfig copies the restartConfig keys of previous into next, so the config that is read
+// later matches the clients that were built at startup.
+func keepRestartConfig(previous, next *Config) {
+	before, after := reflect.ValueOf(previous).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < before.NumField(); i++ {
+		if containsString(restartConfig, before.Type().Field(i).Tag.Get("mapstructure")) {
+			after.Field(i).Set(before.Field(i))
+		}
+	}
+}
+
+func formatConfigValue(value reflect.Value) string {
+	if value.Kind() == reflect.String {
+		return fmt.Sprintf("%q", value.String())
+	}
+	return fmt.Sprintf("%v", value.Interface())
+}
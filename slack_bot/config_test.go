@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeepRestartConfig(t *testing.T) {
+	t.Parallel()
+	previous := &Config{SlackBotToken: "xoxb-old", MetricsAddr: ":9090", OpenAIModel: "gpt-4o", ChannelID: "C1", GasAlertThreshold: 1}
+	next := &Config{SlackBotToken: "xoxb-new", MetricsAddr: ":9191", OpenAIModel: "other", ChannelID: "C2", GasAlertThreshold: 2}
+
+	keepRestartConfig(previous, next)
+	want := Config{SlackBotToken: "xoxb-old", MetricsAddr: ":9090", OpenAIModel: "gpt-4o", ChannelID: "C2", GasAlertThreshold: 2}
+	if !reflect.DeepEqual(*next, want) {
+		t.Errorf("expected the startup keys kept and the rest reloaded, got %+v", *next)
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	t.Parallel()
+	base := Config{
+		SlackBotToken:     "xoxb-old",
+		ChannelID:         "C1",
+		LogLevel:          "info",
+		GasAlertThreshold: 0.5,
+		GasCheckInterval:  time.Hour,
+		AdminUsers:        []string{"U1"},
+	}
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		changes []string
+		restart []string
+	}{
+		{name: "nothing changed", change: func(*Config) {}},
+		{
+			name:    "strings are quoted",
+			change:  func(c *Config) { c.LogLevel = "debug" },
+			changes: []string{`log_level "info" -> "debug"`},
+		},
+		{
+			name: "numbers, durations and lists",
+			change: func(c *Config) {
+				c.GasAlertThreshold = 1.5
+				c.GasCheckInterval = 30 * time.Minute
+				c.AdminUsers = []string{"U1", "U2"}
+			},
+			changes: []string{"gas_alert_threshold 0.5 -> 1.5", "gas_check_interval 1h0m0s -> 30m0s", "admin_users [U1] -> [U1 U2]"},
+		},
+		{
+			name: "secrets are shown without values",
+			change: func(c *Config) {
+				c.BearerToken = "new-bearer"
+				c.OpenAIAPIKey = "sk-new"
+			},
+			changes: []string{"bearer_token changed", "openai_api_key changed"},
+			restart: []string{"openai_api_key"},
+		},
+		{
+			name: "startup keys need a restart",
+			change: func(c *Config) {
+				c.SlackBotToken = "xoxb-new"
+				c.ChannelID = "C2"
+				c.MetricsAddr = ":9090"
+			},
+			changes: []string{"slack_bot_token changed", `channel_id "C1" -> "C2"`, `metrics_addr "" -> ":9090"`},
+			restart: []string{"slack_bot_token", "metrics_addr"},
+		},
+	}
+	for _, test := range tests {
+		next := base
+		next.AdminUsers = append([]string(nil), base.AdminUsers...)
+		test.change(&next)
+		changes, restart := diffConfig(&base, &next)
+		if !reflect.DeepEqual(changes, test.changes) || !reflect.DeepEqual(restart, test.restart) {
+			t.Errorf("%s: diffConfig = %q restart %q, want %q restart %q", test.name, changes, restart, test.changes, test.restart)
+		}
+	}
+}
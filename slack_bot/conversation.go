@@ -0,0 +1,129 @@
+package main
+
+import (
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// conversationRule turns a sentence into the slash command it means.
+type conversationRule struct {
+	pattern *regexp.Regexp
+	command func(match []string) (string, string)
+}
+
+var (
+	mentionPattern = regexp.MustCompile(`<@[A-Z0-9]+(\|[^>]*)?>`)
+	pleasePattern  = regexp.MustCompile(`(?i)\bplease `)
+
+	conversationRules = []conversationRule{
+		{regexp.MustCompile(`(?i)^(?:hi|hello|hey)\b`), fixedCommand("/hi", "")},
+		{regexp.MustCompile(`(?i)^(?:help|what can you do)\b`), fixedCommand("/help", "")},
+		// "order 2 kebapche", "I'd like two burgers please", "order kebapche 2"
+		{regexp.MustCompile(`(?i)^(?:order|i'?d like|i would like|i want|give me|get me)\s+(\d+|an?|one|two|three|four|five|six|seven|eight|nine|ten)\s+(\S+)(?:\s+please)?$`), orderCommand(1, 2)},
+		{regexp.MustCompile(`(?i)^order\s+(\S+)\s+(\d+)$`), orderCommand(2, 1)},
+		{regexp.MustCompile(`(?i)^start(?:\s+a)?(?:\s+(?:new\s+)?session)?(?:\s+(?:until|at|till|deadline))?\s+(\d{1,2}:\d{2})$`), func(match []string) (string, string) {
+			return "/start", match[1]
+		}},
+		{regexp.MustCompile(`(?i)\bgas\b`), fixedCommand("/gas", "")},
+		{regexp.MustCompile(`(?i)\bshopping\b|what (?:should|do) we (?:buy|need)`), fixedCommand("/shopping-list", "")},
+		{regexp.MustCompile(`(?i)\bmenu\b|what can i order|what(?:'s| is) there to eat`), fixedCommand("/menu", "")},
+		{regexp.MustCompile(`(?i)\bbeers?\b`), fixedCommand("/beer", "")},
+		{regexp.MustCompile(`(?i)\bhistory\b.*\b(week|month)\b|\b(week|month)\b.*\bhistory\b`), func(match []string) (string, string) {
+			return "/history", strings.ToLower(match[1] + match[2])
+		}},
+		{regexp.MustCompile(`(?i)\bhistory\b`), fixedCommand("/history", "")},
+		{regexp.MustCompile(`(?i)\bleaderboard\b|who(?:'s| is) the (?:best|master) chef`), fixedCommand("/leaderboard", "")},
+		{regexp.MustCompile(`(?i)^i'?m (?:the chef|on the grill|grilling)|^i am (?:the chef|on the grill|grilling)`), fixedCommand("/chef", "")},
+		{regexp.MustCompile(`(?i)\bschedules?\b`), fixedCommand("/schedule", "list")},
+	}
+
+	numberWords = map[string]int{
+		"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+		"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+	}
+)
+
+func fixedCommand(command, text string) func([]string) (string, string) {
+	return func([]string) (string, string) {
+		return command, text
+	}
+}
+
+// orderCommand builds /order from the quantity and item groups of a match.
+func orderCommand(quantityGroup, itemGroup int) func([]string) (string, string) {
+	return func(match []string) (string, string) {
+		quantity := match[quantityGroup]
+		if n, ok := numberWords[strings.ToLower(quantity)]; ok {
+			quantity = strconv.Itoa(n)
+		}
+		return "/order", match[itemGroup] + " " + quantity
+	}
+}
+
+// parseConversation maps a mention or DM to a slash command. A message that is already a valid command
+// without the slash, e.g. "menu add burger 4 5.99 300", is passed on as it is.
+func parseConversation(text string) (string, string, bool) {
+	text = strings.TrimSpace(mentionPattern.ReplaceAllString(text, ""))
+	fields := strings.Fields(strings.TrimPrefix(text, "/"))
+	if len(fields) > 0 {
+		if command, ok := findCommand(strings.ToLower(fields[0])); ok {
+			if call := command.invocation(fields[1:]); validateArgs(call.schema, call.args) == nil {
+				return command.Name, strings.Join(fields[1:], " "), true
+			}
+		}
+	}
+
+	// The rules ignore case, the item names are passed on as written.
+	normalized := strings.TrimRight(strings.Join(strings.Fields(text), " "), "?!. ")
+	normalized = pleasePattern.ReplaceAllString(strings.ReplaceAll(normalized, "’", "'"), "")
+
+	for _, rule := range conversationRules {
+		if match := rule.pattern.FindStringSubmatch(normalized); match != nil {
+			command, args := rule.command(match)
+			return command, args, true
+		}
+	}
+	return "", "", false
+}
+
+func (b *Bot) handleEventsAPI(event slackevents.EventsAPIEvent) {
+	if event.Type != slackevents.CallbackEvent {
+		return
+	}
+
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		b.handleConversation(ev.Channel, ev.User, ev.Text, ev.TimeStamp)
+	case *slackevents.MessageEvent:
+		// Only direct messages from people; the bot's own replies come back as message events too.
+		if ev.ChannelType != "im" || ev.BotID != "" || ev.SubType != "" || ev.User == "" {
+			return
+		}
+		b.handleConversation(ev.Channel, ev.User, ev.Text, ev.TimeStamp)
+	case *slackevents.FileSharedEvent:
+		b.handleFileShared(ev)
+	}
+}
+
+func (b *Bot) handleConversation(channelID, userID, text, timestamp string) {
+	command, args, ok := parseConversation(text)
+	if !ok {
+		slog.Info("Did not understand message", "text", text, "user", userID, "channel", channelID)
+		postMessage(b.poster, channelID, "Sorry, I didn't get that. Try \"order 2 kebapche\", \"how much gas is left?\" or \"what's on the menu\", or see /help.")
+		return
+	}
+
+	// The message timestamp stands in for the trigger ID so the reply gets a correlation ID like a slash command.
+	b.handleSlashCommand(slack.SlashCommand{
+		Command:   command,
+		Text:      args,
+		UserID:    userID,
+		ChannelID: channelID,
+		TriggerID: channelID + "-" + timestamp,
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParseConversationKeepsItemCase(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		text, command, args string
+	}{
+		{"order 2 Kebapche", "/order", "Kebapche 2"},
+		{"<@U0BOT> Order two Kufte please", "/order", "Kufte 2"},
+		{"I'd like A Burger", "/order", "Burger 1"},
+		{"order Kebapche 3", "/order", "Kebapche 3"},
+		{"Show me the HISTORY of this Month", "/history", "month"},
+		{"How much GAS is left?", "/gas", ""},
+	}
+	for _, test := range tests {
+		command, args, ok := parseConversation(test.text)
+		if !ok || command != test.command || args != test.args {
+			t.Errorf("parseConversation(%q) = %q %q %v, want %q %q", test.text, command, args, ok, test.command, test.args)
+		}
+	}
+}
+
+func TestOrderMatchesMenuCase(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.menu.items["Sarmi"] = ItemInfo{ItemName: "Sarmi", SecondsToCook: 300, CapacityOnGrill: 6}
+
+	h.run(testChef, "/start 12:30")
+	h.bot.handleConversation(testChannel, "U1", "order 2 Sarmi", "1717761600.000100")
+	h.expectMessage("Order placed: Sarmi 2")
+	h.bot.handleConversation(testChannel, "U2", "order 3 sarmi", "1717761600.000200")
+	h.expectMessage("Order placed: Sarmi 3")
+	h.run("U3", "/order KEBAPCHE 1")
+	h.expectMessage("Order placed: kebapche 1")
+
+	session, _ := h.bot.session.snapshot()
+	if quantities := session.ItemQuantities(); quantities["Sarmi"] != 5 || quantities["kebapche"] != 1 {
+		t.Errorf("expected the orders under the menu's names, got %+v", quantities)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ImageDescriber turns an image into text following the prompt. /receipt uses it to read receipts.
+type ImageDescriber interface {
+	DescribeImage(ctx context.Context, data []byte, mimeType, prompt string) (string, error)
+}
+
+const (
+	defaultVisionModel = "gpt-4o"
+	describeTimeout    = time.Minute
+)
+
+// newImageDescriber picks the describer from IMAGE_DESCRIBER: "openai" for an OpenAI-compatible API
+// at OPENAI_BASE_URL, or "local" for a stand-in that works without any service. Without IMAGE_DESCRIBER
+// the OpenAI one is used when it is configured.
+func newImageDescriber(cfg *Config) ImageDescriber {
+	if cfg.ImageDescriber == "openai" {
+		return newOpenAIDescriber(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL, cfg.OpenAIModel)
+	}
+	slog.Warn("Receipts are read by the local stand-in, set OPENAI_API_KEY or OPENAI_BASE_URL to read their contents")
+	return localDescriber{}
+}
+
+// openAIDescriber asks a vision model through the chat completions API. Any OpenAI-compatible server works.
+type openAIDescriber struct {
+	client *openai.Client
+	model  string
+}
+
+func newOpenAIDescriber(apiKey, baseURL, model string) *openAIDescriber {
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	return &openAIDescriber{client: openai.NewClientWithConfig(config), model: model}
+}
+
+func (d *openAIDescriber) DescribeImage(ctx context.Context, data []byte, mimeType, prompt string) (string, error) {
+	imageURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+	response, err := d.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: d.model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: "You are a helpful assistant."},
+			{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: prompt},
+				{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: imageURL}},
+			}},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("chat completion: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("chat completion returned no choices")
+	}
+	return response.Choices[0].Message.Content, nil
+}
+
+// localDescriber only reports what can be seen without a model: the image format, size and dimensions.
+type localDescriber struct{}
+
+func (localDescriber) DescribeImage(ctx context.Context, data []byte, mimeType, prompt string) (string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Sprintf("A %s file of %d KB.", mimeType, len(data)/1024), nil
+	}
+	return fmt.Sprintf("A %dx%d %s image of %d KB.", config.Width, config.Height, format, len(data)/1024), nil
+}
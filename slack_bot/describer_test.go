@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// visionRequest is the part of a chat completion request the describer fills in. The system message
+// has plain text content, so only the user message is decoded into it.
+type visionRequest struct {
+	Model string `json:"model"`
+	User  struct {
+		Content []struct {
+			Type     string `json:"type"`
+			Text     string `json:"text"`
+			ImageURL struct {
+				URL string `json:"url"`
+			} `json:"image_url"`
+		} `json:"content"`
+	}
+}
+
+func TestOpenAIDescriber(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		want     string
+		fails    bool
+	}{
+		{
+			name:     "the first choice is the answer",
+			status:   http.StatusOK,
+			response: `{"choices": [{"message": {"role": "assistant", "content": "{\"total\": 21}"}}, {"message": {"content": "other"}}]}`,
+			want:     `{"total": 21}`,
+		},
+		{name: "no choices", status: http.StatusOK, response: `{"choices": []}`, fails: true},
+		{name: "server error", status: http.StatusInternalServerError, response: `{"error": {"message": "overloaded"}}`, fails: true},
+	}
+	for _, test := range tests {
+		var path, auth string
+		var request visionRequest
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path, auth = r.URL.Path, r.Header.Get("Authorization")
+			var body struct {
+				Model    string            `json:"model"`
+				Messages []json.RawMessage `json:"messages"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Messages) != 2 {
+				http.Error(w, "unexpected request", http.StatusBadRequest)
+				return
+			}
+			request.Model = body.Model
+			if err := json.Unmarshal(body.Messages[1], &request.User); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.response))
+		}))
+
+		describer := newOpenAIDescriber("sk-test", server.URL+"/v1", "vision-model")
+		text, err := describer.DescribeImage(context.Background(), []byte("receipt"), "image/png", "Read the receipt")
+		server.Close()
+
+		if (err != nil) != test.fails || text != test.want {
+			t.Errorf("%s: DescribeImage = %q, %v", test.name, text, err)
+		}
+		if path != "/v1/chat/completions" || auth != "Bearer sk-test" || request.Model != "vision-model" {
+			t.Errorf("%s: sent to %s with %q for model %q", test.name, path, auth, request.Model)
+		}
+		content := request.User.Content
+		if len(content) != 2 || content[0].Text != "Read the receipt" ||
+			content[1].ImageURL.URL != "data:image/png;base64,"+base64.StdEncoding.EncodeToString([]byte("receipt")) {
+			t.Errorf("%s: expected the prompt and the image as a data URL, got %+v", test.name, content)
+		}
+	}
+}
+
+func TestLocalDescriber(t *testing.T) {
+	t.Parallel()
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		data     []byte
+		mimeType string
+		want     string
+	}{
+		{photo.Bytes(), "image/png", "A 40x30 png image of 0 KB."},
+		{bytes.Repeat([]byte("%PDF"), 1024), "application/pdf", "A application/pdf file of 4 KB."},
+	}
+	for _, test := range tests {
+		text, err := localDescriber{}.DescribeImage(context.Background(), test.data, test.mimeType, "Read the receipt")
+		if err != nil || text != test.want {
+			t.Errorf("DescribeImage(%s) = %q, %v, want %q", test.mimeType, text, err, test.want)
+		}
+	}
+}
+
+func TestNewImageDescriber(t *testing.T) {
+	t.Parallel()
+	if _, ok := newImageDescriber(&Config{ImageDescriber: "openai", OpenAIBaseURL: "http://localhost/v1"}).(*openAIDescriber); !ok {
+		t.Error("expected the OpenAI describer for IMAGE_DESCRIBER=openai")
+	}
+	if _, ok := newImageDescriber(&Config{ImageDescriber: "local"}).(localDescriber); !ok {
+		t.Error("expected the local describer for IMAGE_DESCRIBER=local")
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// SessionFeedback is one participant's answers to the post-session survey.
+type SessionFeedback struct {
+	Items      map[string]ItemFeedback `json:"items"`
+	ChefRating int                     `json:"chef_rating,omitempty"`
+	Comment    string                  `json:"comment,omitempty"`
+	Submitted  time.Time               `json:"submitted"`
+}
+
+type ItemFeedback struct {
+	Rating   int    `json:"rating"`
+	Doneness string `json:"doneness"`
+}
+
+// ItemFeedbackStats aggregates the survey answers for one menu item.
+type ItemFeedbackStats struct {
+	Item        string
+	RatingSum   int
+	RatingCount int
+	Undercooked int
+	JustRight   int
+	Overcooked  int
+}
+
+const (
+	feedbackOpenAction  = "feedback_open"
+	feedbackCallbackID  = "feedback_survey"
+	chefRatingBlock     = "chef_rating"
+	commentBlock        = "comment"
+	ratingBlockPrefix   = "rating:"
+	donenessBlockPrefix = "doneness:"
+
+	donenessUndercooked = "undercooked"
+	donenessJustRight   = "just right"
+	donenessOvercooked  = "overcooked"
+
+	// Items need this many doneness votes before the bot suggests changing their cooking time.
+	minDonenessVotes = 3
+	// Share of votes one way (after subtracting the other way) that flags an item.
+	donenessFlagShare = 0.3
+)
+
+func (s ItemFeedbackStats) AverageRating() float64 {
+	if s.RatingCount == 0 {
+		return 0
+	}
+	return float64(s.RatingSum) / float64(s.RatingCount)
+}
+
+func (s ItemFeedbackStats) DonenessVotes() int {
+	return s.Undercooked + s.JustRight + s.Overcooked
+}
+
+// DonenessBias is positive when the item comes out undercooked and negative when overcooked, from -1 to 1.
+func (s ItemFeedbackStats) DonenessBias() float64 {
+	if s.DonenessVotes() == 0 {
+		return 0
+	}
+	return float64(s.Undercooked-s.Overcooked) / float64(s.DonenessVotes())
+}
+
+// sendFeedbackSurveys DMs every participant of a closed session a button that opens the survey.
+func (b *Bot) sendFeedbackSurveys(session SessionRecord) {
+	if !b.config().FeedbackSurveys {
+		return
+	}
+
+	text := fmt.Sprintf("Thanks for joining the grill session on %s! How was the food?", session.Started.Format("Mon 02 Jan"))
+	if session.Chef != "" {
+		text = fmt.Sprintf("Thanks for joining the grill session on %s! How was the food <@%s> made?",
+			session.Started.Format("Mon 02 Jan"), session.Chef)
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(feedbackOpenAction, session.ID,
+				slack.NewTextBlockObject(slack.PlainTextType, "Rate the food", true, false))),
+	}
+
+	for _, user := range session.Participants() {
+		channel, _, _, err := b.dialogs.OpenConversation(&slack.OpenConversationParameters{Users: []string{user}})
+		if err != nil {
+			slog.Error("Failed to open DM", "user", user, "err", err)
+			continue
+		}
+		if _, _, err := b.poster.PostMessage(channel.ID, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...)); err != nil {
+			slog.Error("Failed to send feedback survey", "user", user, "err", err)
+		}
+	}
+}
+
+// openFeedbackSurvey opens the survey modal for the session in the button's value.
+func (b *Bot) openFeedbackSurvey(callback slack.InteractionCallback, sessionID string) {
+	session, ok := b.findSession(sessionID)
+	if !ok {
+		slog.Warn("Feedback requested for unknown session", "session", sessionID)
+		return
+	}
+
+	quantities := make(map[string]int)
+	for _, order := range session.Orders {
+		if order.User == callback.User.ID {
+			quantities[order.Item] += order.Quantity
+		}
+	}
+	if len(quantities) == 0 {
+		quantities = session.ItemQuantities()
+	}
+
+	var ratingOptions []*slack.OptionBlockObject
+	for rating := 5; rating >= 1; rating-- {
+		ratingOptions = append(ratingOptions, slack.NewOptionBlockObject(strconv.Itoa(rating),
+			slack.NewTextBlockObject(slack.PlainTextType, strings.Repeat("⭐", rating), true, false), nil))
+	}
+	var donenessOptions []*slack.OptionBlockObject
+	for _, doneness := range [][2]string{
+		{donenessUndercooked, "Undercooked"},
+		{donenessJustRight, "Just right"},
+		{donenessOvercooked, "Overcooked"},
+	} {
+		donenessOptions = append(donenessOptions, slack.NewOptionBlockObject(doneness[0],
+			slack.NewTextBlockObject(slack.PlainTextType, doneness[1], false, false), nil))
+	}
+
+	var blocks []slack.Block
+	for _, total := range sortedTotals(quantities) {
+		blocks = append(blocks,
+			slack.NewInputBlock(ratingBlockPrefix+total.Item,
+				slack.NewTextBlockObject(slack.PlainTextType, "How was the "+total.Item+"?", false, false), nil,
+				slack.NewOptionsSelectBlockElement(slack.OptTypeStatic,
+					slack.NewTextBlockObject(slack.PlainTextType, "Rating", false, false), "rating", ratingOptions...)),
+			slack.NewInputBlock(donenessBlockPrefix+total.Item,
+				slack.NewTextBlockObject(slack.PlainTextType, "Was the "+total.Item+" cooked right?", false, false), nil,
+				slack.NewRadioButtonsBlockElement("doneness", donenessOptions...)))
+	}
+
+	if session.Chef != "" && session.Chef != callback.User.ID {
+		chefRating := slack.NewInputBlock(chefRatingBlock,
+			slack.NewTextBlockObject(slack.PlainTextType, "How would you rate the chef?", false, false), nil,
+			slack.NewOptionsSelectBlockElement(slack.OptTypeStatic,
+				slack.NewTextBlockObject(slack.PlainTextType, "Rating", false, false), "rating", ratingOptions...))
+		chefRating.Optional = true
+		blocks = append(blocks, chefRating)
+	}
+
+	commentInput := slack.NewPlainTextInputBlockElement(
+		slack.NewTextBlockObject(slack.PlainTextType, "Anything the chef should know?", false, false), "comment")
+	commentInput.Multiline = true
+	comment := slack.NewInputBlock(commentBlock,
+		slack.NewTextBlockObject(slack.PlainTextType, "Comments", false, false), nil, commentInput)
+	comment.Optional = true
+	blocks = append(blocks, comment)
+
+	view := slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "Grill feedback", false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, "Send", false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks:          slack.Blocks{BlockSet: blocks},
+		PrivateMetadata: session.ID,
+		CallbackID:      feedbackCallbackID,
+	}
+	if _, err := b.dialogs.OpenView(callback.TriggerID, view); err != nil {
+		slog.Error("Failed to open feedback survey", "err", err)
+	}
+}
+
+// handleFeedbackSubmission stores the survey answers on the session. The chef rating also counts for the leaderboard.
+func (b *Bot) handleFeedbackSubmission(callback slack.InteractionCallback) {
+	if callback.View.State == nil {
+		return
+	}
+
+	feedback := SessionFeedback{Items: make(map[string]ItemFeedback), Submitted: b.clock.Now()}
+	for blockID, actions := range callback.View.State.Values {
+		for _, action := range actions {
+			switch {
+			case strings.HasPrefix(blockID, ratingBlockPrefix):
+				item := strings.TrimPrefix(blockID, ratingBlockPrefix)
+				itemFeedback := feedback.Items[item]
+				itemFeedback.Rating, _ = strconv.Atoi(action.SelectedOption.Value)
+				feedback.Items[item] = itemFeedback
+			case strings.HasPrefix(blockID, donenessBlockPrefix):
+				item := strings.TrimPrefix(blockID, donenessBlockPrefix)
+				itemFeedback := feedback.Items[item]
+				itemFeedback.Doneness = action.SelectedOption.Value
+				feedback.Items[item] = itemFeedback
+			case blockID == chefRatingBlock:
+				feedback.ChefRating, _ = strconv.Atoi(action.SelectedOption.Value)
+			case blockID == commentBlock:
+				feedback.Comment = action.Value
+			}
+		}
+	}
+
+	userID := callback.User.ID
+	err := b.updateSessions(func(sessions []SessionRecord) []SessionRecord {
+		for i := range sessions {
+			if sessions[i].ID != callback.View.PrivateMetadata {
+				continue
+			}
+			if sessions[i].Feedback == nil {
+				sessions[i].Feedback = make(map[string]SessionFeedback)
+			}
+			sessions[i].Feedback[userID] = feedback
+			if feedback.ChefRating > 0 && sessions[i].Chef != userID {
+				if sessions[i].Ratings == nil {
+					sessions[i].Ratings = make(map[string]int)
+				}
+				sessions[i].Ratings[userID] = feedback.ChefRating
+			}
+		}
+		return sessions
+	})
+	if err != nil {
+		slog.Error("Failed to store feedback", "err", err)
+	}
+}
+
+func (b *Bot) handleFeedback(cmd slack.SlashCommand) {
+	period := "all"
+	if args := strings.Fields(cmd.Text); len(args) > 0 {
+		period = args[0]
+	}
+
+	var since time.Time
+	if period != "all" {
+		var ok bool
+		since, ok = historySince(period, b.clock.Now())
+		if !ok {
+			b.replyEphemeral(cmd, "Usage: `/feedback [week|month|all]`")
+			return
+		}
+	}
+
+	sessions, err := b.loadSessions()
+	if err != nil {
+		b.replyError(cmd, "Failed to load the session history.", err)
+		return
+	}
+
+	items := itemFeedbackStats(sessions, since)
+	if len(items) == 0 {
+		postMessage(b.poster, cmd.ChannelID, "No feedback has been given in this period yet.")
+		return
+	}
+
+	postMessage(b.poster, cmd.ChannelID, formatFeedbackReport(sessions, items, b.menu.Items(), since))
+}
+
+func itemFeedbackStats(sessions []SessionRecord, since time.Time) []ItemFeedbackStats {
+	byItem := make(map[string]*ItemFeedbackStats)
+	for _, session := range sessions {
+		if session.Closed.Before(since) {
+			continue
+		}
+		for _, feedback := range session.Feedback {
+			for item, itemFeedback := range feedback.Items {
+				stats, ok := byItem[item]
+				if !ok {
+					stats = &ItemFeedbackStats{Item: item}
+					byItem[item] = stats
+				}
+				if itemFeedback.Rating > 0 {
+					stats.RatingSum += itemFeedback.Rating
+					stats.RatingCount++
+				}
+				switch itemFeedback.Doneness {
+				case donenessUndercooked:
+					stats.Undercooked++
+				case donenessJustRight:
+					stats.JustRight++
+				case donenessOvercooked:
+					stats.Overcooked++
+				}
+			}
+		}
+	}
+
+	var items []ItemFeedbackStats
+	for _, stats := range byItem {
+		items = append(items, *stats)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Item < items[j].Item })
+	return items
+}
+
+// chefFoodRatings sums the item ratings of every session each chef cooked, as [sum, count].
+func chefFoodRatings(sessions []SessionRecord, since time.Time) map[string][2]int {
+	ratings := make(map[string][2]int)
+	for _, session := range sessions {
+		if session.Chef == "" || session.Closed.Before(since) {
+			continue
+		}
+		total := ratings[session.Chef]
+		for _, feedback := range session.Feedback {
+			for _, itemFeedback := range feedback.Items {
+				if itemFeedback.Rating > 0 {
+					total[0] += itemFeedback.Rating
+					total[1]++
+				}
+			}
+		}
+		ratings[session.Chef] = total
+	}
+	return ratings
+}
+
+// cookingTimeFlag suggests a new seconds to cook when the doneness votes lean clearly one way.
+func cookingTimeFlag(stats ItemFeedbackStats, secondsToCook int) (int, bool) {
+	if stats.DonenessVotes() < minDonenessVotes || secondsToCook <= 0 {
+		return 0, false
+	}
+	bias := stats.DonenessBias()
+	if bias > -donenessFlagShare && bias < donenessFlagShare {
+		return 0, false
+	}
+	// Up to a quarter more (or less) time when everyone agrees.
+	return int(float64(secondsToCook) * (1 + 0.25*bias)), true
+}
+
+func formatFeedbackReport(sessions []SessionRecord, items []ItemFeedbackStats, itemData map[string]ItemInfo, since time.Time) string {
+	var builder strings.Builder
+	builder.WriteString("Food feedback :memo:\n*Items*\n")
+	var flags []string
+	for _, stats := range items {
+		builder.WriteString(fmt.Sprintf("• %s: %.1f/5 (%d ratings), %d undercooked / %d just right / %d overcooked\n",
+			stats.Item, stats.AverageRating(), stats.RatingCount, stats.Undercooked, stats.JustRight, stats.Overcooked))
+
+		secondsToCook := itemData[stats.Item].SecondsToCook
+		if suggested, ok := cookingTimeFlag(stats, secondsToCook); ok {
+			direction := "undercooked"
+			if suggested < secondsToCook {
+				direction = "overcooked"
+			}
+			flags = append(flags, fmt.Sprintf("• %s is usually %s — try %d seconds instead of %d",
+				stats.Item, direction, suggested, secondsToCook))
+		}
+	}
+
+	chefs := chefFoodRatings(sessions, since)
+	var names []string
+	for chef, total := range chefs {
+		if total[1] > 0 {
+			names = append(names, chef)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		builder.WriteString("*Chefs*\n")
+		for _, chef := range names {
+			total := chefs[chef]
+			builder.WriteString(fmt.Sprintf("• <@%s>: food rated %.1f/5 (%d ratings)\n", chef, float64(total[0])/float64(total[1]), total[1]))
+		}
+	}
+
+	if len(flags) > 0 {
+		builder.WriteString("*Cooking times to check*\n" + strings.Join(flags, "\n"))
+	}
+	return builder.String()
+}
+
+func (b *Bot) findSession(id string) (SessionRecord, bool) {
+	sessions, err := b.loadSessions()
+	if err != nil {
+		slog.Error("Failed to load sessions", "err", err)
+		return SessionRecord{}, false
+	}
+	for _, session := range sessions {
+		if session.ID == id {
+			return session, true
+		}
+	}
+	return SessionRecord{}, false
+}
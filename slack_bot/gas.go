@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// GrillRecord is one cooking session as reported by the scale. Gas values are in grams.
+type GrillRecord struct {
+	StartGas           float64
+	EndGas             float64
+	AverageConsumption float64 // grams per second
+	Start              time.Time
+	End                time.Time
+}
+
+type GasForecast struct {
+	RemainingKg  float64
+	KgPerSession float64
+	GramsPerSec  float64
+	SessionsLeft float64
+	RunOutDate   time.Time // zero when the trend can't be fitted
+	LastReading  time.Time
+	SessionCount int
+	AvgSeconds   int
+}
+
+const (
+	gramsPerKg                 = 1000.0
+	refillJumpGrams            = 500.0
+	defaultGasAlertThresholdKg = 1.0
+	defaultGasCheckInterval    = 30 * time.Minute
+)
+
+func (b *Bot) handleGas(cmd slack.SlashCommand) {
+	records, err := b.grill.GrillRecords()
+	if err != nil {
+		b.replyError(cmd, "Failed to fetch the grill history.", err)
+		return
+	}
+
+	bottle, err := b.grill.BottleWeight()
+	if err != nil {
+		b.replyError(cmd, "Failed to fetch the gas bottle weight.", err)
+		return
+	}
+
+	forecast, ok := forecastGas(records, bottle)
+	if !ok {
+		postMessage(b.poster, cmd.ChannelID, "Not enough grill sessions recorded to estimate the gas level yet.")
+		return
+	}
+
+	postMessage(b.poster, cmd.ChannelID, formatGasForecast(forecast, b.config().Location()))
+}
+
+// watchGasLevel periodically re-fits the consumption trend and warns the channel before the bottle runs out.
+func (b *Bot) watchGasLevel() {
+	enabled := true
+	for {
+		cfg := b.config()
+		if (cfg.ServerGrill == "" && cfg.TelemetryAddr == "") || cfg.ChannelID == "" {
+			if enabled {
+				slog.Info("SERVER_GRILL (or TELEMETRY_ADDR) or CHANNEL_ID not set, gas level alerts are disabled")
+			}
+			enabled = false
+		} else {
+			enabled = true
+			b.handleInFlight(func() { b.checkGasLevel(cfg.ChannelID) })
+		}
+		time.Sleep(cfg.GasCheckInterval)
+	}
+}
+
+func (b *Bot) checkGasLevel(channelID string) {
+	records, err := b.grill.GrillRecords()
+	if err != nil {
+		slog.Error("Failed to fetch grill records", "err", err)
+		return
+	}
+
+	bottle, err := b.grill.BottleWeight()
+	if err != nil {
+		slog.Error("Failed to fetch the gas bottle weight", "err", err)
+		return
+	}
+
+	forecast, ok := forecastGas(records, bottle)
+	if !ok {
+		return
+	}
+
+	threshold := b.config().GasAlertThreshold
+	cookSeconds := b.nextSessionCookSeconds(forecast)
+	neededKg := forecast.GramsPerSec * float64(cookSeconds) / gramsPerKg
+
+	var reason string
+	switch {
+	case forecast.RemainingKg < threshold:
+		reason = fmt.Sprintf("only %.2f kg of gas left (alert threshold is %.2f kg)", forecast.RemainingKg, threshold)
+	case neededKg > forecast.RemainingKg:
+		reason = fmt.Sprintf("the next session needs about %.2f kg for %s of cooking, but only %.2f kg is left",
+			neededKg, formatSeconds(cookSeconds), forecast.RemainingKg)
+	}
+
+	if reason == "" {
+		b.gasAlertActive = false
+		return
+	}
+	if b.gasAlertActive {
+		return
+	}
+	b.gasAlertActive = true
+
+	postMessage(b.poster, channelID, "<!here> Time to refill the gas bottle: "+reason+".\n"+formatGasForecast(forecast, b.config().Location()))
+}
+
+// nextSessionCookSeconds estimates how long the grill will burn next time: the open session's
+// orders if there are any, otherwise what the usual participants of recent sessions order when a
+// session is scheduled. The average recorded session is used when nothing is scheduled.
+func (b *Bot) nextSessionCookSeconds(forecast GasForecast) int {
+	itemData := b.menu.Items()
+	if session, ok := b.session.snapshot(); ok && len(session.Orders) > 0 {
+		if seconds := estimateCookSeconds(session.ItemQuantities(), itemData); seconds > 0 {
+			return seconds
+		}
+	}
+	if _, ok := b.nextScheduledOpen(); ok {
+		sessions, err := b.loadSessions()
+		if err != nil {
+			slog.Error("Failed to load the session history", "err", err)
+			return forecast.AvgSeconds
+		}
+		if seconds := estimateCookSeconds(usualQuantities(recentSessions(sessions)), itemData); seconds > 0 {
+			return seconds
+		}
+	}
+	return forecast.AvgSeconds
+}
+
+func estimateCookSeconds(quantities map[string]int, itemData map[string]ItemInfo) int {
+	total := 0
+	for item, quantity := range quantities {
+		itemInfo, ok := itemData[item]
+		if !ok || itemInfo.CapacityOnGrill <= 0 {
+			continue
+		}
+		total += calculateCookingTime(quantity, itemInfo.CapacityOnGrill, itemInfo.SecondsToCook)
+	}
+	return total
+}
+
+// forecastGas fits gas level over time since the last refill and extrapolates when it reaches zero.
+// Records must be sorted by end time, their gas includes the bottleGrams of the empty bottle.
+func forecastGas(records []GrillRecord, bottleGrams float64) (GasForecast, bool) {
+	if len(records) == 0 {
+		return GasForecast{}, false
+	}
+
+	// A session that starts noticeably heavier than the previous one ended means the bottle was swapped.
+	first := 0
+	for i := 1; i < len(records); i++ {
+		if records[i].StartGas-records[i-1].EndGas > refillJumpGrams {
+			first = i
+		}
+	}
+	window := records[first:]
+	last := window[len(window)-1]
+
+	forecast := GasForecast{
+		RemainingKg:  math.Max(last.EndGas-bottleGrams, 0) / gramsPerKg,
+		LastReading:  last.End,
+		SessionCount: len(window),
+	}
+
+	var usedGrams, seconds, rateSum float64
+	rates := 0
+	for _, record := range window {
+		usedGrams += record.StartGas - record.EndGas
+		duration := record.End.Sub(record.Start).Seconds()
+		seconds += duration
+		switch {
+		case record.AverageConsumption > 0:
+			rateSum += record.AverageConsumption
+			rates++
+		case duration > 0:
+			rateSum += (record.StartGas - record.EndGas) / duration
+			rates++
+		}
+	}
+	forecast.KgPerSession = usedGrams / gramsPerKg / float64(len(window))
+	forecast.AvgSeconds = int(seconds / float64(len(window)))
+	if rates > 0 {
+		forecast.GramsPerSec = rateSum / float64(rates)
+	}
+	if forecast.KgPerSession > 0 {
+		forecast.SessionsLeft = forecast.RemainingKg / forecast.KgPerSession
+	}
+
+	// Least-squares fit of kg against hours, using both ends of every session.
+	var xs, ys []float64
+	origin := window[0].Start
+	for _, record := range window {
+		xs = append(xs, record.Start.Sub(origin).Hours(), record.End.Sub(origin).Hours())
+		ys = append(ys, (record.StartGas-bottleGrams)/gramsPerKg, (record.EndGas-bottleGrams)/gramsPerKg)
+	}
+	if slope, ok := linearSlope(xs, ys); ok && slope < 0 {
+		hoursLeft := forecast.RemainingKg / -slope
+		forecast.RunOutDate = last.End.Add(time.Duration(hoursLeft * float64(time.Hour)))
+	}
+
+	return forecast, true
+}
+
+func linearSlope(xs, ys []float64) (float64, bool) {
+	if len(xs) < 2 {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var numerator, denominator float64
+	for i := range xs {
+		numerator += (xs[i] - meanX) * (ys[i] - meanY)
+		denominator += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if denominator == 0 {
+		return 0, false
+	}
+	return numerator / denominator, true
+}
+
+// formatGasForecast describes forecast with its dates in location.
+func formatGasForecast(forecast GasForecast, location *time.Location) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Gas left: %.2f kg (last reading %s).\n", forecast.RemainingKg, forecast.LastReading.In(location).Format("02 Jan 15:04")))
+	builder.WriteString(fmt.Sprintf("Average session uses %.2f kg over %s.\n", forecast.KgPerSession, formatSeconds(forecast.AvgSeconds)))
+	if forecast.SessionsLeft > 0 {
+		builder.WriteString(fmt.Sprintf("That's enough for about %.1f more sessions.\n", forecast.SessionsLeft))
+	}
+	if !forecast.RunOutDate.IsZero() {
+		builder.WriteString(fmt.Sprintf("At the current pace the bottle runs out around %s.", forecast.RunOutDate.In(location).Format("Mon 02 Jan")))
+	} else {
+		builder.WriteString("Not enough data to predict the run-out date yet.")
+	}
+	return builder.String()
+}
+
+func formatSeconds(seconds int) string {
+	minutes := (seconds + 30) / 60
+	if minutes < 60 {
+		return fmt.Sprintf("%d min", minutes)
+	}
+	return fmt.Sprintf("%dh %02d min", minutes/60, minutes%60)
+}
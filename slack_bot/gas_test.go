@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGasAlertEstimatesScheduledSession(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.settings.Load().GasAlertThreshold = 0.5
+
+	// Two hour-long sessions burned 0.5 kg each, 1 kg is left: enough for the average session.
+	start := h.clock.Now().AddDate(0, 0, -14)
+	h.grill.records = []GrillRecord{
+		{StartGas: 2000, EndGas: 1500, Start: start, End: start.Add(time.Hour)},
+		{StartGas: 1500, EndGas: 1000, Start: start.AddDate(0, 0, 7), End: start.AddDate(0, 0, 7).Add(time.Hour)},
+	}
+	// Three people usually order 50 kebapche each, 15 batches of 10 minutes.
+	err := h.bot.updateSessions(func(sessions []SessionRecord) []SessionRecord {
+		for _, record := range h.grill.records {
+			session := SessionRecord{ID: record.Start.Format(time.RFC3339), Closed: record.Start}
+			for _, user := range []string{"U1", "U2", "U3"} {
+				session.Orders = append(session.Orders, SessionOrder{User: user, Item: "kebapche", Quantity: 50})
+			}
+			sessions = append(sessions, session)
+		}
+		return sessions
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.bot.checkGasLevel(testChannel)
+	h.expectNoMessage("Time to refill")
+
+	h.run(testChef, `/schedule add "Fri 13:00 deadline 12:45"`)
+	h.expectMessage("Schedule 1 added")
+	h.bot.checkGasLevel(testChannel)
+	h.expectMessage("the next session needs about 1.25 kg for 2h 30 min of cooking, but only 1.00 kg is left")
+}
+
+func TestGasAlertSubtractsTheBottle(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.settings.Load().GasAlertThreshold = 1
+
+	// 10.8 kg on the scale with a 10 kg bottle is 0.8 kg of gas, below the 1 kg threshold.
+	start := h.clock.Now().AddDate(0, 0, -7)
+	h.grill.bottle = 10000
+	h.grill.records = []GrillRecord{{StartGas: 11300, EndGas: 10800, Start: start, End: start.Add(time.Hour)}}
+	h.bot.checkGasLevel(testChannel)
+	h.expectMessage("only 0.80 kg of gas left (alert threshold is 1.00 kg)")
+}
+
+func TestForecastGas(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	// session grills for an hour from hour, burning from start to end grams.
+	session := func(hour int, start, end float64) GrillRecord {
+		begin := day.Add(time.Duration(hour) * time.Hour)
+		return GrillRecord{StartGas: start, EndGas: end, Start: begin, End: begin.Add(time.Hour)}
+	}
+	// after is hours past day.
+	after := func(hours float64) time.Time {
+		return day.Add(time.Duration(hours * float64(time.Hour)))
+	}
+
+	tests := []struct {
+		name      string
+		records   []GrillRecord
+		bottle    float64
+		ok        bool
+		remaining float64
+		sessions  int
+		perKg     float64
+		runOut    time.Time // zero when no trend can be fitted
+	}{
+		{name: "no records"},
+		{
+			name:      "one session",
+			records:   []GrillRecord{session(0, 5000, 4500)},
+			ok:        true,
+			remaining: 4.5,
+			sessions:  1,
+			perKg:     0.5,
+			runOut:    after(1 + 9),
+		},
+		{
+			name:      "steady use of 0.5 kg an hour",
+			records:   []GrillRecord{session(0, 5000, 4500), session(1, 4500, 4000), session(2, 4000, 3500)},
+			ok:        true,
+			remaining: 3.5,
+			sessions:  3,
+			perKg:     0.5,
+			runOut:    after(3 + 7),
+		},
+		{
+			name:      "a refill starts a new window",
+			records:   []GrillRecord{session(0, 3000, 1000), session(1, 1000, 200), session(10, 9000, 8000), session(11, 8000, 7000)},
+			ok:        true,
+			remaining: 7,
+			sessions:  2,
+			perKg:     1,
+			runOut:    after(12 + 7),
+		},
+		{
+			// The fitted slope is -0.48 kg an hour.
+			name:      "a small rise is not a refill",
+			records:   []GrillRecord{session(0, 5000, 4000), session(2, 4300, 3300)},
+			ok:        true,
+			remaining: 3.3,
+			sessions:  2,
+			perKg:     1,
+			runOut:    after(3 + 3.3/0.48),
+		},
+		{
+			name:      "the empty bottle is not gas",
+			records:   []GrillRecord{session(0, 15000, 14500), session(1, 14500, 14000)},
+			bottle:    10000,
+			ok:        true,
+			remaining: 4,
+			sessions:  2,
+			perKg:     0.5,
+			runOut:    after(2 + 8),
+		},
+		{
+			name:     "at the tare the bottle is empty now",
+			records:  []GrillRecord{session(0, 11000, 10500), session(1, 10500, 10000)},
+			bottle:   10000,
+			ok:       true,
+			sessions: 2,
+			perKg:    0.5,
+			runOut:   after(2),
+		},
+		{
+			// The scale drifts a little, the gas left never goes negative.
+			name:     "below the tare",
+			records:  []GrillRecord{session(0, 10600, 10100), session(1, 10100, 9900)},
+			bottle:   10000,
+			ok:       true,
+			sessions: 2,
+			perKg:    0.35,
+			runOut:   after(2),
+		},
+		{
+			name:      "no gas used",
+			records:   []GrillRecord{session(0, 5000, 5000), session(1, 5000, 5000)},
+			ok:        true,
+			remaining: 5,
+			sessions:  2,
+		},
+	}
+	for _, test := range tests {
+		forecast, ok := forecastGas(test.records, test.bottle)
+		if ok != test.ok {
+			t.Errorf("%s: ok = %v", test.name, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !closeTo(forecast.RemainingKg, test.remaining) || forecast.SessionCount != test.sessions || !closeTo(forecast.KgPerSession, test.perKg) {
+			t.Errorf("%s: got %.2f kg left, %d sessions, %.2f kg per session", test.name, forecast.RemainingKg, forecast.SessionCount, forecast.KgPerSession)
+		}
+		if forecast.RunOutDate.IsZero() != test.runOut.IsZero() || forecast.RunOutDate.Sub(test.runOut).Abs() > time.Second {
+			t.Errorf("%s: runs out %v, want %v", test.name, forecast.RunOutDate, test.runOut)
+		}
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const defaultGrillStatusInterval = time.Minute
+
+// watchGrillStatus polls the Grill_Status record the scale updates and announces when cooking starts and stops.
+func (b *Bot) watchGrillStatus() {
+	enabled := true
+	for {
+		cfg := b.config()
+		if cfg.ServerGrillStatus == "" || cfg.ChannelID == "" {
+			if enabled {
+				slog.Info("SERVER_GRILL_STATUS or CHANNEL_ID not set, grill notifications are disabled")
+			}
+			enabled = false
+		} else if active, err := b.grill.GrillActive(); err != nil {
+			enabled = true
+			slog.Error("Failed to fetch grill status", "err", err)
+		} else {
+			enabled = true
+			if !b.initGrillStatus(active) {
+				b.handleInFlight(func() { b.handleGrillStatusChange(cfg.ChannelID, active) })
+			}
+		}
+		time.Sleep(cfg.GrillStatusInterval)
+	}
+}
+
+// initGrillStatus records the first observed state without announcing it, so a restart
+// doesn't post "Grill is on" for a session that started earlier. It reports whether it did.
+func (b *Bot) initGrillStatus(active bool) bool {
+	b.grillMu.Lock()
+	defer b.grillMu.Unlock()
+
+	if b.grillStatusKnown {
+		return false
+	}
+	b.grillActive = active
+	b.grillStatusKnown = true
+	if active {
+		b.grillOnSince = b.clock.Now()
+	}
+	return true
+}
+
+// handleGrillStatusChange posts a notification when the grill turns on or off. Repeated states are ignored.
+func (b *Bot) handleGrillStatusChange(channelID string, active bool) {
+	session, sessionOpen := b.session.snapshot()
+
+	b.grillMu.Lock()
+	defer b.grillMu.Unlock()
+
+	if b.grillStatusKnown && active == b.grillActive {
+		return
+	}
+	b.grillActive = active
+	b.grillStatusKnown = true
+
+	if active {
+		b.grillOnSince = b.clock.Now()
+		message := "Grill is on :fire:"
+		if sessionOpen {
+			message += fmt.Sprintf(" Cooking for the order session open until %s (%d orders so far).",
+				session.Deadline.Format("15:04"), len(session.Orders))
+		}
+		postMessage(b.poster, channelID, message)
+		return
+	}
+
+	message := "Grill is off"
+	if record, ok := b.latestGrillRecordSince(b.grillOnSince); ok {
+		usedKg := (record.StartGas - record.EndGas) / gramsPerKg
+		seconds := int(record.End.Sub(record.Start).Seconds())
+		message += fmt.Sprintf(" — used %.1f kg in %s", usedKg, formatSeconds(seconds))
+	} else if !b.grillOnSince.IsZero() {
+		message += fmt.Sprintf(" after %s", formatSeconds(int(b.clock.Now().Sub(b.grillOnSince).Seconds())))
+	}
+	if sessionOpen {
+		message += fmt.Sprintf(". The order session is open until %s", session.Deadline.Format("15:04"))
+	}
+	b.grillOnSince = time.Time{}
+
+	postMessage(b.poster, channelID, message+".")
+}
+
+// latestGrillRecordSince returns the newest grill record that ended after the grill was switched on.
+// The scale posts the record right before it reports the stop, so it's normally already there.
+func (b *Bot) latestGrillRecordSince(since time.Time) (GrillRecord, bool) {
+	records, err := b.grill.GrillRecords()
+	if err != nil {
+		slog.Error("Failed to fetch grill records", "err", err)
+		return GrillRecord{}, false
+	}
+	if len(records) == 0 {
+		return GrillRecord{}, false
+	}
+
+	latest := records[len(records)-1]
+	if !since.IsZero() && latest.End.Before(since) {
+		return GrillRecord{}, false
+	}
+	return latest, true
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	testChannel = "C_GRILL"
+	testChef    = "U_CHEF"
+	waitTimeout = 2 * time.Second
+)
+
+// postedMessage is a message the bot sent through the fake Slack client.
+type postedMessage struct {
+	Channel   string
+	User      string
+	Text      string
+	Ephemeral bool
+}
+
+// fakeSlack records what the bot posts, uploads and opens, and serves the files and channel messages a
+// test adds.
+type fakeSlack struct {
+	mu       sync.Mutex
+	messages []postedMessage
+	files    map[string]fakeFile
+	history  []slack.Message
+	uploads  []slack.FileUploadParameters
+	views    []slack.ModalViewRequest
+	dms      []string
+	authErr  error
+}
+
+// fakeFile is a file shared in Slack, its content is what GetFile downloads from the file's URL.
+type fakeFile struct {
+	file    slack.File
+	content []byte
+}
+
+// fakeBotUser is the user the bot posts and uploads as.
+const fakeBotUser = "U_BOT"
+
+func (s *fakeSlack) record(message postedMessage, options []slack.MsgOption) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", message.Channel, "", options...)
+	if err == nil {
+		message.Text = values.Get("text")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+}
+
+func (s *fakeSlack) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	s.record(postedMessage{Channel: channelID}, options)
+	return channelID, fmt.Sprint(time.Now().UnixNano()), nil
+}
+
+func (s *fakeSlack) PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error) {
+	s.record(postedMessage{Channel: channelID, User: userID, Ephemeral: true}, options)
+	return fmt.Sprint(time.Now().UnixNano()), nil
+}
+
+func (s *fakeSlack) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	s.record(postedMessage{Channel: channelID}, options)
+	return channelID, timestamp, "", nil
+}
+
+func (s *fakeSlack) AuthTest() (*slack.AuthTestResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.authErr != nil {
+		return nil, s.authErr
+	}
+	return &slack.AuthTestResponse{UserID: fakeBotUser}, nil
+}
+
+// addFile shares a file in the test channel from userID.
+func (s *fakeSlack) addFile(userID string, file slack.File, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string]fakeFile)
+	}
+	file.User = userID
+	file.URLPrivateDownload = "https://files.slack.test/" + file.ID
+	s.files[file.ID] = fakeFile{file: file, content: content}
+	// History comes newest first.
+	message := slack.Message{}
+	message.User = userID
+	message.Files = []slack.File{file}
+	s.history = append([]slack.Message{message}, s.history...)
+}
+
+func (s *fakeSlack) GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &slack.GetConversationHistoryResponse{Messages: append([]slack.Message(nil), s.history...)}, nil
+}
+
+func (s *fakeSlack) GetConversationReplies(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error) {
+	return nil, false, "", nil
+}
+
+func (s *fakeSlack) GetFile(downloadURL string, writer io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, file := range s.files {
+		if file.file.URLPrivateDownload == downloadURL {
+			_, err := writer.Write(file.content)
+			return err
+		}
+	}
+	return fmt.Errorf("no file at %s", downloadURL)
+}
+
+func (s *fakeSlack) GetFileInfo(fileID string, count, page int) (*slack.File, []slack.Comment, *slack.Paging, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.files[fileID]
+	if !ok {
+		return nil, nil, nil, errors.New("file_not_found")
+	}
+	return &file.file, nil, nil, nil
+}
+
+func (s *fakeSlack) UploadFile(params slack.FileUploadParameters) (*slack.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads = append(s.uploads, params)
+	return &slack.File{ID: fmt.Sprintf("F_UPLOAD%d", len(s.uploads)), User: fakeBotUser}, nil
+}
+
+func (s *fakeSlack) OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dms = append(s.dms, params.Users...)
+	channel := &slack.Channel{}
+	channel.ID = "D_" + strings.Join(params.Users, "_")
+	return channel, false, false, nil
+}
+
+func (s *fakeSlack) OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.views = append(s.views, view)
+	return &slack.ViewResponse{}, nil
+}
+
+var _ SlackClient = (*fakeSlack)(nil)
+
+func (s *fakeSlack) posted() []postedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]postedMessage(nil), s.messages...)
+}
+
+// find returns the first message containing text.
+func (s *fakeSlack) find(text string) (postedMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, message := range s.messages {
+		if strings.Contains(message.Text, text) {
+			return message, true
+		}
+	}
+	return postedMessage{}, false
+}
+
+// fakeMenu serves the menu from memory, item IDs are the names prefixed with "id-".
+type fakeMenu struct {
+	mu    sync.Mutex
+	items map[string]ItemInfo
+}
+
+func (m *fakeMenu) Items() map[string]ItemInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := make(map[string]ItemInfo, len(m.items))
+	for name, info := range m.items {
+		items[name] = info
+	}
+	return items
+}
+
+func (m *fakeMenu) ItemID(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[name]; !ok {
+		return ""
+	}
+	return "id-" + name
+}
+
+func (m *fakeMenu) AddItem(item map[string]interface{}) error {
+	// /menu add passes the numbers on as typed.
+	name, _ := item["item name"].(string)
+	seconds, _ := strconv.Atoi(fmt.Sprint(item["seconds to cook"]))
+	capacity, _ := strconv.Atoi(fmt.Sprint(item["capacity on grill"]))
+	section, _ := item["store section"].(string)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[name] = ItemInfo{ItemName: name, SecondsToCook: seconds, CapacityOnGrill: capacity, StoreSection: section}
+	return nil
+}
+
+func (m *fakeMenu) SetSecondsToCook(name string, seconds int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.items[name]
+	if !ok {
+		return fmt.Errorf("item %s not found", name)
+	}
+	info.SecondsToCook = seconds
+	m.items[name] = info
+	return nil
+}
+
+// fakeOrders records the summaries and hands back recent as the orders of the last hour. Summaries
+// returns history followed by what was recorded.
+type fakeOrders struct {
+	mu        sync.Mutex
+	summaries []map[string]interface{}
+	history   []OrderSummaryRecord
+	recent    []string
+	sent      [][]string
+	clock     Clock
+}
+
+func (o *fakeOrders) SendOrderSummary(summary map[string]interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.summaries = append(o.summaries, summary)
+}
+
+func (o *fakeOrders) RecentOrders() []string {
+	return o.recent
+}
+
+func (o *fakeOrders) SendRecentOrders(orders []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, orders)
+}
+
+func (o *fakeOrders) Summaries() ([]OrderSummaryRecord, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	summaries := append([]OrderSummaryRecord(nil), o.history...)
+	for _, summary := range o.summaries {
+		itemID, _ := summary["item ordered"].(string)
+		quantity, _ := summary["summed quantity"].(int)
+		seconds, _ := summary["seconds to cook"].(int)
+		summaries = append(summaries, OrderSummaryRecord{
+			Created:       o.clock.Now(),
+			ItemID:        itemID,
+			Item:          strings.TrimPrefix(itemID, "id-"),
+			Quantity:      quantity,
+			SecondsToCook: seconds,
+		})
+	}
+	return summaries, nil
+}
+
+// fakeGrill serves the scale's sessions from records and keeps what the telemetry server relayed.
+type fakeGrill struct {
+	mu       sync.Mutex
+	records  []GrillRecord
+	bottle   float64
+	active   bool
+	readings []GrillReading
+	statuses []GrillStatusReading
+}
+
+func (g *fakeGrill) GrillRecords() ([]GrillRecord, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]GrillRecord(nil), g.records...), nil
+}
+
+func (g *fakeGrill) BottleWeight() (float64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.bottle, nil
+}
+
+func (g *fakeGrill) GrillActive() (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.active, nil
+}
+
+func (g *fakeGrill) ForwardReading(reading GrillReading) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.readings = append(g.readings, reading)
+	return nil
+}
+
+func (g *fakeGrill) ForwardStatus(reading GrillStatusReading) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.statuses = append(g.statuses, reading)
+	return nil
+}
+
+// fakeDescriber answers every image with reply and records the type of each image it was asked about.
+type fakeDescriber struct {
+	mu        sync.Mutex
+	reply     string
+	mimeTypes []string
+}
+
+func (d *fakeDescriber) DescribeImage(ctx context.Context, data []byte, mimeType, prompt string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mimeTypes = append(d.mimeTypes, mimeType)
+	return d.reply, nil
+}
+
+// fakeClock only moves when the test advances it, firing the timers that are due.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+func (c *fakeClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// harness replays slash commands through handleSlashCommand against a bot built on the fakes.
+type harness struct {
+	t      *testing.T
+	bot    *Bot
+	slack  *fakeSlack
+	menu   *fakeMenu
+	orders *fakeOrders
+	grill  *fakeGrill
+	clock  *fakeClock
+	// describer reads the receipts, attachments keeps them in the temporary directory.
+	describer   *fakeDescriber
+	attachments *AttachmentStore
+	// settings is the bot's config, a test can change it as a reload would.
+	settings *atomic.Pointer[Config]
+	seq      int
+}
+
+// newHarness sets up a fresh bot at 12:00 UTC with testChef as an admin, everything stored under a
+// temporary directory.
+func newHarness(t *testing.T) *harness {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC)}
+	h := &harness{
+		t:     t,
+		slack: &fakeSlack{},
+		menu: &fakeMenu{items: map[string]ItemInfo{
+			"kebapche": {ItemName: "kebapche", SecondsToCook: 600, CapacityOnGrill: 10},
+			"kufte":    {ItemName: "kufte", SecondsToCook: 480, CapacityOnGrill: 8},
+		}},
+		orders:    &fakeOrders{clock: clock},
+		grill:     &fakeGrill{},
+		clock:     clock,
+		describer: &fakeDescriber{},
+		attachments: &AttachmentStore{
+			dir:       filepath.Join(dir, "attachments"),
+			maxBytes:  defaultAttachmentMaxMB << 20,
+			retention: defaultAttachmentRetention,
+		},
+		settings: new(atomic.Pointer[Config]),
+	}
+	h.restart()
+	t.Cleanup(func() {
+		// A session left open keeps its deadline watcher, it must be parked on the clock before the
+		// temporary directory is removed.
+		if _, ok := h.bot.session.snapshot(); ok {
+			h.eventually("the deadline watcher to wait", func() bool { return h.clock.waiting() > 0 })
+		}
+	})
+
+	h.settings.Store(&Config{
+		ChannelID:        testChannel,
+		location:         time.UTC,
+		ReminderOffset:   defaultReminderOffset,
+		SessionStore:     filepath.Join(dir, "sessions.json"),
+		OpenSessionStore: filepath.Join(dir, "open_session.json"),
+		AdminUsers:       []string{testChef},
+		DefaultRole:      string(roleMember),
+		RoleStore:        filepath.Join(dir, "roles.json"),
+		AuditLog:         filepath.Join(dir, "audit.jsonl"),
+		ScheduleStore:    filepath.Join(dir, "schedules.json"),
+		TelemetryDir:     dir,
+		ShutdownTimeout:  waitTimeout,
+	})
+	return h
+}
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// restart replaces the bot with a fresh one on the same fakes and stores, as if the process was restarted.
+func (h *harness) restart() {
+	h.bot = &Bot{
+		settings:    h.settings,
+		poster:      h.slack,
+		files:       h.slack,
+		dialogs:     h.slack,
+		menu:        h.menu,
+		orders:      h.orders,
+		grill:       h.grill,
+		clock:       h.clock,
+		describer:   h.describer,
+		attachments: h.attachments,
+	}
+}
+
+// run replays "/command text" from userID in the test channel, as handleEvents would, and returns the command.
+func (h *harness) run(userID, line string) slack.SlashCommand {
+	h.t.Helper()
+	h.seq++
+	name, text, _ := strings.Cut(line, " ")
+	cmd := slack.SlashCommand{
+		Command:   name,
+		Text:      text,
+		UserID:    userID,
+		ChannelID: testChannel,
+		TriggerID: fmt.Sprintf("%s-trigger-%d", h.t.Name(), h.seq),
+	}
+	h.bot.handleSlashCommand(cmd)
+	return cmd
+}
+
+// click presses a button with value on a message the bot posted in the test channel, as userID.
+func (h *harness) click(userID, actionID, value string) {
+	h.t.Helper()
+	var callback slack.InteractionCallback
+	callback.Type = slack.InteractionTypeBlockActions
+	callback.User.ID = userID
+	callback.Channel.ID = testChannel
+	callback.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: actionID, Value: value}}
+	h.bot.handleInteraction(callback)
+}
+
+// advance moves the clock once the deadline watcher is waiting on it, so no reminder is skipped.
+func (h *harness) advance(d time.Duration) {
+	h.t.Helper()
+	h.eventually("the deadline watcher to wait", func() bool { return h.clock.waiting() > 0 })
+	h.clock.Advance(d)
+}
+
+// expectMessage waits for a message containing text, the deadline watcher posts from its own goroutine.
+func (h *harness) expectMessage(text string) postedMessage {
+	h.t.Helper()
+	var message postedMessage
+	h.eventually(fmt.Sprintf("a message containing %q", text), func() bool {
+		var ok bool
+		message, ok = h.slack.find(text)
+		return ok
+	})
+	return message
+}
+
+func (h *harness) expectNoMessage(text string) {
+	h.t.Helper()
+	if message, ok := h.slack.find(text); ok {
+		h.t.Fatalf("unexpected message %+v", message)
+	}
+}
+
+func (h *harness) eventually(what string, condition func() bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s, posted so far: %+v", what, h.slack.posted())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// OrderSummaryRecord is one per-item summary that summarizeOrders sends to SERVER_ORDER.
+type OrderSummaryRecord struct {
+	Created       time.Time
+	ItemID        string
+	Item          string
+	Quantity      int
+	SecondsToCook int
+}
+
+type ItemTotal struct {
+	Item     string
+	Quantity int
+}
+
+type HistoryReport struct {
+	Label          string
+	Since          time.Time
+	Sessions       []GrillRecord
+	Orders         []OrderSummaryRecord
+	TotalSeconds   int
+	GasKg          float64
+	AvgConsumption float64 // grams per second
+	ItemTotals     []ItemTotal
+}
+
+type historyChart struct {
+	Filename string
+	Title    string
+	PNG      []byte
+}
+
+const defaultDigestTime = "09:00"
+
+func (b *Bot) handleHistory(cmd slack.SlashCommand) {
+	args := strings.Fields(cmd.Text)
+	charts := len(args) > 0 && args[0] == "chart"
+	if charts {
+		args = args[1:]
+	}
+
+	// Charts default to a month so there is more than one week to compare.
+	period := "week"
+	if charts {
+		period = "month"
+	}
+	if len(args) > 0 {
+		period = args[0]
+	}
+
+	since, ok := historySince(period, b.clock.Now())
+	if !ok {
+		b.replyEphemeral(cmd, "Usage: `/history [week|month]` or `/history chart [week|month]`")
+		return
+	}
+
+	report, err := b.buildHistoryReport(period, since)
+	if err != nil {
+		b.replyError(cmd, "Failed to fetch the grill history.", err)
+		return
+	}
+
+	if charts {
+		b.uploadHistoryCharts(cmd.ChannelID, report)
+		return
+	}
+
+	postMessage(b.poster, cmd.ChannelID, formatHistoryReport(report))
+
+	csvData, err := historyCSV(report)
+	if err != nil {
+		slog.Error("Failed to build history CSV", "err", err)
+		return
+	}
+	_, err = b.files.UploadFile(slack.FileUploadParameters{
+		Content:  string(csvData),
+		Filetype: "csv",
+		Filename: fmt.Sprintf("grill-history-%s-%s.csv", period, b.clock.Now().Format("2006-01-02")),
+		Title:    "Grill history for the last " + period,
+		Channels: []string{cmd.ChannelID},
+	})
+	if err != nil {
+		b.replyError(cmd, "Failed to upload the CSV export.", err)
+	}
+}
+
+func historySince(period string, now time.Time) (time.Time, bool) {
+	switch period {
+	case "week":
+		return now.AddDate(0, 0, -7), true
+	case "month":
+		return now.AddDate(0, -1, 0), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func (b *Bot) buildHistoryReport(label string, since time.Time) (HistoryReport, error) {
+	report := HistoryReport{Label: label, Since: since}
+
+	records, err := b.grill.GrillRecords()
+	if err != nil {
+		return report, err
+	}
+	var rateSum float64
+	for _, record := range records {
+		if record.Start.Before(since) {
+			continue
+		}
+		report.Sessions = append(report.Sessions, record)
+		report.TotalSeconds += int(record.End.Sub(record.Start).Seconds())
+		report.GasKg += (record.StartGas - record.EndGas) / gramsPerKg
+		rateSum += record.AverageConsumption
+	}
+	if len(report.Sessions) > 0 {
+		report.AvgConsumption = rateSum / float64(len(report.Sessions))
+	}
+
+	summaries, err := b.orders.Summaries()
+	if err != nil {
+		return report, err
+	}
+	totals := make(map[string]int)
+	for _, summary := range summaries {
+		if summary.Created.Before(since) {
+			continue
+		}
+		report.Orders = append(report.Orders, summary)
+		totals[summary.Item] += summary.Quantity
+	}
+	report.ItemTotals = sortedTotals(totals)
+
+	return report, nil
+}
+
+func formatHistoryReport(report HistoryReport) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Grill history for the last %s (since %s):\n", report.Label, report.Since.Format("02 Jan")))
+	builder.WriteString(fmt.Sprintf("• Sessions: %d\n", len(report.Sessions)))
+	builder.WriteString(fmt.Sprintf("• Total cook time: %s\n", formatSeconds(report.TotalSeconds)))
+	builder.WriteString(fmt.Sprintf("• Gas burned: %.2f kg\n", report.GasKg))
+	builder.WriteString(fmt.Sprintf("• Average consumption: %.2f g/s\n", report.AvgConsumption))
+
+	if len(report.ItemTotals) == 0 {
+		builder.WriteString("No orders were placed in this period.")
+		return builder.String()
+	}
+
+	builder.WriteString("Most ordered items:\n")
+	for i, total := range report.ItemTotals {
+		if i == 5 {
+			break
+		}
+		builder.WriteString(fmt.Sprintf("%d. %s x%d\n", i+1, total.Item, total.Quantity))
+	}
+	return builder.String()
+}
+
+func historyCSV(report HistoryReport) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	rows := [][]string{{"type", "start", "end", "duration seconds", "gas used kg", "average consumption g/s", "item", "quantity", "seconds to cook"}}
+	for _, record := range report.Sessions {
+		rows = append(rows, []string{
+			"grill",
+			record.Start.Format(time.RFC3339),
+			record.End.Format(time.RFC3339),
+			strconv.Itoa(int(record.End.Sub(record.Start).Seconds())),
+			strconv.FormatFloat((record.StartGas-record.EndGas)/gramsPerKg, 'f', 3, 64),
+			strconv.FormatFloat(record.AverageConsumption, 'f', 3, 64),
+			"", "", "",
+		})
+	}
+	for _, summary := range report.Orders {
+		rows = append(rows, []string{
+			"order",
+			summary.Created.Format(time.RFC3339),
+			"", "", "", "",
+			summary.Item,
+			strconv.Itoa(summary.Quantity),
+			strconv.Itoa(summary.SecondsToCook),
+		})
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// buildHistoryCharts renders the report's charts, dated in location.
+func buildHistoryCharts(report HistoryReport, location *time.Location) ([]historyChart, error) {
+	var charts []historyChart
+
+	var gasPoints []ChartPoint
+	var sessionLabels []string
+	var sessionValues [][]float64
+	for _, record := range report.Sessions {
+		gasPoints = append(gasPoints,
+			ChartPoint{Time: record.Start.In(location), Value: record.StartGas / gramsPerKg},
+			ChartPoint{Time: record.End.In(location), Value: record.EndGas / gramsPerKg})
+		sessionLabels = append(sessionLabels, record.Start.In(location).Format("02 Jan"))
+		sessionValues = append(sessionValues, []float64{(record.StartGas - record.EndGas) / gramsPerKg})
+	}
+
+	gasPNG, err := renderLineChart("Gas level over the last "+report.Label, "kg", gasPoints)
+	if err != nil {
+		return nil, err
+	}
+	charts = append(charts, historyChart{Filename: "gas-level.png", Title: "Gas level", PNG: gasPNG})
+
+	consumptionPNG, err := renderBarChart("Gas used per session", "kg", sessionLabels, nil, sessionValues)
+	if err != nil {
+		return nil, err
+	}
+	charts = append(charts, historyChart{Filename: "gas-per-session.png", Title: "Gas used per session", PNG: consumptionPNG})
+
+	weekLabels, items, weekValues := itemsPerWeek(report, location)
+	itemsPNG, err := renderBarChart("Items ordered per week", "pcs", weekLabels, items, weekValues)
+	if err != nil {
+		return nil, err
+	}
+	charts = append(charts, historyChart{Filename: "items-per-week.png", Title: "Items ordered per week", PNG: itemsPNG})
+
+	return charts, nil
+}
+
+// itemsPerWeek groups the order summaries by the Monday of their week in location, one series per item
+// in the order of report.ItemTotals.
+func itemsPerWeek(report HistoryReport, location *time.Location) ([]string, []string, [][]float64) {
+	var items []string
+	itemIndex := make(map[string]int)
+	for _, total := range report.ItemTotals {
+		itemIndex[total.Item] = len(items)
+		items = append(items, total.Item)
+	}
+
+	var labels []string
+	var values [][]float64
+	weekIndex := make(map[string]int)
+	for _, summary := range report.Orders {
+		created := summary.Created.In(location)
+		offset := (int(created.Weekday()) + 6) % 7
+		label := created.AddDate(0, 0, -offset).Format("02 Jan")
+		index, ok := weekIndex[label]
+		if !ok {
+			index = len(labels)
+			weekIndex[label] = index
+			labels = append(labels, label)
+			values = append(values, make([]float64, len(items)))
+		}
+		values[index][itemIndex[summary.Item]] += float64(summary.Quantity)
+	}
+	return labels, items, values
+}
+
+func (b *Bot) uploadHistoryCharts(channelID string, report HistoryReport) {
+	charts, err := buildHistoryCharts(report, b.config().Location())
+	if err != nil {
+		slog.Error("Failed to render history charts", "err", err)
+		postMessage(b.poster, channelID, "Failed to render the charts.")
+		return
+	}
+
+	for _, chart := range charts {
+		_, err := b.files.UploadFile(slack.FileUploadParameters{
+			Reader:   bytes.NewReader(chart.PNG),
+			Filetype: "png",
+			Filename: chart.Filename,
+			Title:    chart.Title,
+			Channels: []string{channelID},
+		})
+		if err != nil {
+			slog.Error("Failed to upload chart", "file", chart.Filename, "err", err)
+			postMessage(b.poster, channelID, "Failed to upload the charts.")
+			return
+		}
+	}
+}
+
+// watchWeeklyDigest posts last week's report with charts to CHANNEL_ID every DIGEST_DAY at DIGEST_TIME.
+// The next digest is worked out again every minute, so a reloaded config moves it.
+func (b *Bot) watchWeeklyDigest() {
+	enabled := true
+	for {
+		cfg := b.config()
+		if cfg.DigestDay == "" || cfg.ChannelID == "" {
+			if enabled {
+				slog.Info("DIGEST_DAY or CHANNEL_ID not set, the weekly digest is disabled")
+			}
+			enabled = false
+			<-b.clock.After(time.Minute)
+			continue
+		}
+
+		// Both are checked when the config is loaded, this only fails if that check is missed.
+		weekday, ok := parseWeekday(cfg.DigestDay)
+		digestAt, err := time.Parse("15:04", cfg.DigestTime)
+		if !ok || err != nil {
+			if enabled {
+				slog.Error("Invalid DIGEST_DAY or DIGEST_TIME, the weekly digest is disabled", "day", cfg.DigestDay, "time", cfg.DigestTime, "err", err)
+			}
+			enabled = false
+			<-b.clock.After(time.Minute)
+			continue
+		}
+		enabled = true
+
+		next := nextWeekly(b.localNow(), weekday, digestAt.Hour(), digestAt.Minute())
+		wait := next.Sub(b.clock.Now())
+		if wait > time.Minute {
+			<-b.clock.After(time.Minute)
+			continue
+		}
+		<-b.clock.After(wait)
+		b.handleInFlight(func() { b.postWeeklyDigest(cfg.ChannelID) })
+	}
+}
+
+func (b *Bot) postWeeklyDigest(channelID string) {
+	since, _ := historySince("week", b.localNow())
+	report, err := b.buildHistoryReport("week", since)
+	if err != nil {
+		slog.Error("Failed to build the weekly digest", "err", err)
+		return
+	}
+
+	postMessage(b.poster, channelID, "Weekly grill digest :bar_chart:\n"+formatHistoryReport(report))
+	b.uploadHistoryCharts(channelID, report)
+}
+
+func parseWeekday(value string) (time.Weekday, bool) {
+	value = strings.ToLower(value)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if value == name || value == name[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// nextWeekly returns the next time after now that falls on weekday at hour:minute.
+func nextWeekly(now time.Time, weekday time.Weekday, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	days := (int(weekday) - int(now.Weekday()) + 7) % 7
+	next = next.AddDate(0, 0, days)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
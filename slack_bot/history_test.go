@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeeklyDigestRunsOnTheBotClock(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	cfg := h.settings.Load()
+	cfg.DigestDay, cfg.DigestTime = "fri", "12:30"
+
+	go h.bot.watchWeeklyDigest()
+	for i := 0; i < 29; i++ {
+		h.advance(time.Minute)
+	}
+	h.expectNoMessage("Weekly grill digest")
+	h.advance(time.Minute)
+	h.expectMessage("Weekly grill digest")
+}
+
+func TestItemsPerWeekUsesConfiguredTimezone(t *testing.T) {
+	t.Parallel()
+	sofia, err := time.LoadLocation("Europe/Sofia")
+	if err != nil {
+		t.Skip(err)
+	}
+	report := HistoryReport{
+		ItemTotals: []ItemTotal{{Item: "kebapche"}},
+		Orders: []OrderSummaryRecord{
+			// Sunday night in UTC is already Monday in Sofia.
+			{Created: time.Date(2024, 6, 9, 23, 30, 0, 0, time.UTC), Item: "kebapche", Quantity: 4},
+			{Created: time.Date(2024, 6, 11, 12, 0, 0, 0, time.UTC), Item: "kebapche", Quantity: 2},
+		},
+	}
+
+	labels, _, values := itemsPerWeek(report, sofia)
+	if len(labels) != 1 || labels[0] != "10 Jun" || values[0][0] != 6 {
+		t.Errorf("expected both orders in the week of 10 Jun, got %v %v", labels, values)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	defaultLogLevel  = "info"
+	defaultLogFormat = "text"
+	redacted         = "[REDACTED]"
+)
+
+var (
+	// logLevel is shared by every handler so a reloaded LOG_LEVEL applies right away.
+	logLevel = new(slog.LevelVar)
+
+	// Slack tokens, bearer headers and OpenAI keys, wherever they show up in a message or value.
+	secretPattern = regexp.MustCompile(`\b(?:xox[abposre]|xapp)-[A-Za-z0-9-]+|(?i:bearer)\s+[A-Za-z0-9._~+/=-]+|\bsk-[A-Za-z0-9_-]{16,}`)
+	// Attributes under these keys are never logged, whatever their value looks like.
+	secretKeyPattern = regexp.MustCompile(`(?i)token|secret|password|authorization|api_?key`)
+)
+
+func parseLogLevel(value string) (slog.Level, bool) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, false
+	}
+	return level, true
+}
+
+// setupLogging makes a redacting slog logger the default, for the log package as well, at LOG_LEVEL in
+// LOG_FORMAT. The secrets of the config in settings are redacted, reloads included.
+func setupLogging(settings *atomic.Pointer[Config]) {
+	cfg := settings.Load()
+	applyLogLevel(cfg)
+	slog.SetDefault(slog.New(newRedactingHandler(os.Stderr, cfg.LogFormat, settings)))
+}
+
+func applyLogLevel(cfg *Config) {
+	// LOG_LEVEL was checked when the config was loaded.
+	level, _ := parseLogLevel(cfg.LogLevel)
+	logLevel.Set(level)
+}
+
+func newRedactingHandler(w io.Writer, format string, settings *atomic.Pointer[Config]) slog.Handler {
+	options := &slog.HandlerOptions{Level: logLevel}
+	if format == "json" {
+		return redactingHandler{slog.NewJSONHandler(w, options), settings}
+	}
+	return redactingHandler{slog.NewTextHandler(w, options), settings}
+}
+
+// slackLogger passes the Slack client's debug output, which includes whole payloads, through slog at debug level.
+func slackLogger() *log.Logger {
+	return slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug)
+}
+
+// slackDebug turns on the Slack client's request and event logging when LOG_LEVEL is debug.
+func slackDebug(cfg *Config) bool {
+	level, _ := parseLogLevel(cfg.LogLevel)
+	return level <= slog.LevelDebug
+}
+
+// redactingHandler removes secrets from the message and the attributes before they reach the wrapped handler.
+// The secrets of the config in settings are removed as well.
+type redactingHandler struct {
+	slog.Handler
+	settings *atomic.Pointer[Config]
+}
+
+func (h redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	cfg := h.settings.Load()
+	clean := slog.NewRecord(record.Time, record.Level, redactSecrets(record.Message, cfg), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		clean.AddAttrs(redactAttr(attr, cfg))
+		return true
+	})
+	return h.Handler.Handle(ctx, clean)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cfg := h.settings.Load()
+	clean := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		clean[i] = redactAttr(attr, cfg)
+	}
+	return redactingHandler{h.Handler.WithAttrs(clean), h.settings}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{h.Handler.WithGroup(name), h.settings}
+}
+
+func redactAttr(attr slog.Attr, cfg *Config) slog.Attr {
+	if secretKeyPattern.MatchString(attr.Key) {
+		return slog.String(attr.Key, redacted)
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, redactSecrets(value.String(), cfg))
+	case slog.KindGroup:
+		group := value.Group()
+		clean := make([]any, len(group))
+		for i, member := range group {
+			clean[i] = redactAttr(member, cfg)
+		}
+		return slog.Group(attr.Key, clean...)
+	case slog.KindAny:
+		// Errors and structs can carry a request URL or header, so they are logged as redacted text.
+		return slog.String(attr.Key, redactSecrets(fmt.Sprint(value.Any()), cfg))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// redactSecrets replaces the secrets of cfg, which may be nil, and anything shaped like a token in text.
+func redactSecrets(text string, cfg *Config) string {
+	if cfg != nil {
+		for _, secret := range []string{cfg.SlackAppToken, cfg.SlackBotToken, cfg.BearerToken, cfg.TelemetryToken, cfg.OpenAIAPIKey} {
+			if len(secret) >= 8 {
+				text = strings.ReplaceAll(text, secret, redacted)
+			}
+		}
+	}
+	return secretPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if fields := strings.Fields(match); len(fields) == 2 {
+			return fields[0] + " " + redacted
+		}
+		return redacted
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRedactingHandler(t *testing.T) {
+	t.Parallel()
+	settings := new(atomic.Pointer[Config])
+	settings.Store(&Config{BearerToken: "backend-secret-123", TelemetryToken: "short"})
+
+	tests := []struct {
+		name  string
+		log   func(*slog.Logger)
+		want  []string
+		leaks []string
+	}{
+		{
+			name:  "configured secret in the message",
+			log:   func(logger *slog.Logger) { logger.Info("calling backend with backend-secret-123") },
+			want:  []string{"calling backend with " + redacted},
+			leaks: []string{"backend-secret-123"},
+		},
+		{
+			name: "short secrets are left alone",
+			log:  func(logger *slog.Logger) { logger.Info("short answer") },
+			want: []string{"short answer"},
+		},
+		{
+			name:  "token shaped values",
+			log:   func(logger *slog.Logger) { logger.Info("sent", "header", "Bearer abc.def", "reply", "xoxb-1234-abcd") },
+			want:  []string{"Bearer " + redacted, "reply=" + redacted},
+			leaks: []string{"abc.def", "xoxb-1234-abcd"},
+		},
+		{
+			name:  "OpenAI keys",
+			log:   func(logger *slog.Logger) { logger.Info("describer", "detail", "key sk-abcdefghijklmnopqrst") },
+			leaks: []string{"sk-abcdefghijklmnopqrst"},
+		},
+		{
+			name: "secret keys whatever the value",
+			log: func(logger *slog.Logger) {
+				logger.Info("config", "slack_bot_token", "plain", "api_key", 987654321, "channel", "C1")
+			},
+			want:  []string{"slack_bot_token=" + redacted, "api_key=" + redacted, "channel=C1"},
+			leaks: []string{"plain", "987654321"},
+		},
+		{
+			name: "nested groups",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", slog.Group("http", slog.String("url", "https://x/?t=xoxp-99-zz"), slog.Group("headers", slog.String("Authorization", "anything"))))
+			},
+			want:  []string{"http.url=" + `"https://x/?t=` + redacted + `"`, "http.headers.Authorization=" + redacted},
+			leaks: []string{"xoxp-99-zz", "anything"},
+		},
+		{
+			name:  "errors",
+			log:   func(logger *slog.Logger) { logger.Error("failed", "err", errors.New("401 for backend-secret-123")) },
+			want:  []string{"401 for " + redacted},
+			leaks: []string{"backend-secret-123"},
+		},
+		{
+			name: "attributes added up front",
+			log: func(logger *slog.Logger) {
+				logger.With("password", "hunter22").WithGroup("grill").Info("ready", "token", "t")
+			},
+			want:  []string{"password=" + redacted, "grill.token=" + redacted},
+			leaks: []string{"hunter22"},
+		},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		test.log(slog.New(newRedactingHandler(&out, "text", settings)))
+		for _, want := range test.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s: expected %q in %q", test.name, want, out.String())
+			}
+		}
+		for _, leak := range test.leaks {
+			if strings.Contains(out.String(), leak) {
+				t.Errorf("%s: %q leaked in %q", test.name, leak, out.String())
+			}
+		}
+	}
+}
@@ -1,24 +1,21 @@
 package main
 
 import (
-	"bytes"
-	"container/heap"
-	"encoding/json"
-	"encoding/base64"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
-	"context"
 
-	"github.com/joho/godotenv"
 	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
-	"github.com/sashabaranov/go-openai"
 )
 
 // Structs
@@ -36,9 +33,11 @@ type ItemInfo struct {
 	ItemName      string `json:"item name"`
 	SecondsToCook int    `json:"seconds to cook"`
 	CapacityOnGrill int  `json:"capacity on grill"`
+	StoreSection  string `json:"store section"`
 }
 
 type Order struct {
+	User     string
 	Item     string
 	Quantity int
 	CookTime int
@@ -59,42 +58,63 @@ func (pq *PriorityQueue) Pop() interface{} {
 	return order
 }
 
-var orderQueue PriorityQueue
-var orderDeadline time.Time
-var ordersEnabled bool
+// defaultReminderOffset is how long before the deadline the channel is reminded to order.
+const defaultReminderOffset = 5 * time.Minute
 
 func main() {
-	loadEnv()
-
-	appToken := os.Getenv("SLACK_APP_TOKEN")
-	botToken := os.Getenv("SLACK_BOT_TOKEN")
+	cfg, configFile, err := loadConfig()
+	if err != nil {
+		slog.Error("Failed to load the configuration", "err", err)
+		os.Exit(1)
+	}
+	settings := new(atomic.Pointer[Config])
+	settings.Store(cfg)
+	setupLogging(settings)
 
-	validateTokens(appToken, botToken)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	client := createSlackClient(botToken, appToken)
+	client := createSlackClient(cfg.SlackBotToken, cfg.SlackAppToken, slackDebug(cfg))
+	backend := newBubbleBackend(settings, realClock{})
+	b := newBot(settings, client, backend, newImageDescriber(cfg), newAttachmentStore(cfg))
 	socketClient := createSocketClient(client)
 
-	go handleEvents(socketClient, client)
-
-	socketClient.Run()
-}
-
-func loadEnv() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
-	}
-}
+	go b.handleEvents(socketClient)
+	go watchConfig(configFile, settings)
+	go b.watchGasLevel()
+	go b.watchGrillStatus()
+	go b.watchWeeklyDigest()
+	go b.watchSchedules()
+	go watchAttachments(b.attachments)
+	telemetryServer := b.startTelemetryServer()
+	metricsServer := startMetricsServer(cfg.MetricsAddr, backend.Ready)
+	b.resumeOpenSession()
+
+	socketCtx, closeSocket := context.WithCancel(context.Background())
+	socketStopped := make(chan error, 1)
+	go func() {
+		socketStopped <- socketClient.RunContext(socketCtx)
+	}()
 
-func validateTokens(appToken, botToken string) {
-	if appToken == "" || botToken == "" {
-		log.Fatalf("SLACK_APP_TOKEN and SLACK_BOT_TOKEN must be set")
+	select {
+	case <-ctx.Done():
+		b.shutdown(telemetryServer, metricsServer)
+		closeSocket()
+		<-socketStopped
+		slog.Info("Shut down")
+	case err := <-socketStopped:
+		slog.Error("Socket mode connection stopped", "err", err)
+		b.shutdown(telemetryServer, metricsServer)
+		os.Exit(1)
 	}
 }
 
-func createSlackClient(botToken, appToken string) *slack.Client {
+// createSlackClient logs requests and events through slog, only when debug is set since they include whole payloads.
+func createSlackClient(botToken, appToken string, debug bool) *slack.Client {
 	return slack.New(
 		botToken,
-		slack.OptionDebug(true),
+		slack.OptionDebug(debug),
+		slack.OptionLog(slackLogger()),
 		slack.OptionAppLevelToken(appToken),
 	)
 }
@@ -102,361 +122,210 @@ func createSlackClient(botToken, appToken string) *slack.Client {
 func createSocketClient(client *slack.Client) *socketmode.Client {
 	return socketmode.New(
 		client,
-		socketmode.OptionDebug(true),
+		socketmode.OptionDebug(client.Debug()),
+		socketmode.OptionLog(slackLogger()),
 	)
 }
 
-func handleEvents(socketClient *socketmode.Client, client *slack.Client) {
+func (b *Bot) handleEvents(socketClient *socketmode.Client) {
 	for evt := range socketClient.Events {
+		trackSocketConnection(evt.Type)
 		switch evt.Type {
 		case socketmode.EventTypeInteractive:
-			// Implement interactive event handling if needed
+			callback, ok := evt.Data.(slack.InteractionCallback)
+			if !ok {
+				slog.Warn("Ignored event with unexpected data", "type", evt.Type, "data", fmt.Sprintf("%T", evt.Data))
+				continue
+			}
+			socketClient.Ack(*evt.Request)
+			if !b.handleInFlight(func() { b.handleInteraction(callback) }) {
+				slog.Info("Ignored interaction while shutting down", "user", callback.User.ID)
+			}
 		case socketmode.EventTypeSlashCommand:
 			cmd, ok := evt.Data.(slack.SlashCommand)
 			if !ok {
-				log.Printf("Ignored %+v\n", evt)
+				slog.Warn("Ignored event with unexpected data", "type", evt.Type, "data", fmt.Sprintf("%T", evt.Data))
+				continue
+			}
+			socketClient.Ack(*evt.Request)
+			if !b.handleInFlight(func() { b.handleSlashCommand(cmd) }) {
+				b.replyEphemeral(cmd, "I'm restarting, please try again in a minute.")
+			}
+		case socketmode.EventTypeEventsAPI:
+			event, ok := evt.Data.(slackevents.EventsAPIEvent)
+			if !ok {
+				slog.Warn("Ignored event with unexpected data", "type", evt.Type, "data", fmt.Sprintf("%T", evt.Data))
 				continue
 			}
 			socketClient.Ack(*evt.Request)
-			handleSlashCommand(client, cmd)
+			b.handleInFlight(func() { b.handleEventsAPI(event) })
 		default:
-			log.Printf("Ignored %+v\n", evt)
+			slog.Debug("Ignored event", "type", evt.Type)
 		}
 	}
 }
 
-func handleSlashCommand(client *slack.Client, cmd slack.SlashCommand) {
-	switch cmd.Command {
-	case "/hi":
-		postMessage(client, cmd.ChannelID, "Hi, I'm Slack Bot. I got your command.")
-	case "/order":
-		handleOrder(client, cmd)
-	case "/start":
-		handleStart(client, cmd)
-	case "/help":
-		message := "This is a Slack bot for managing orders. Here's how it works:\n" +
-			"1. Type `/start {time}` to start a new session for orders. The `{time}` argument sets a deadline after which no new orders will be accepted.\n" +
-			"2. Type `/order {item_from_the_menu} {quantity}` to place a new order. The `{item_from_the_menu}` argument specifies what you want to eat, and the `quantity` specifies how much you want.\n" +
-			"NOTE: You can see the full menu with the command `/menu` and if you want to add a new product, you need to type" + 
-			" `/menu add {item} {capacity_on_grill} {price} {seconds_to_cook}` where {item} is the product you want to add, " +
-			"{capacity_on_grill} is how many of this items can be placed on the grill at the same type, {price} is how much it costs "+
-			"and {seconds_to_cook} is how many seconds it must be cooked (approximately)."
-		postMessage(client, cmd.ChannelID, message)
-	case "/menu":
-		handleMenu(client, cmd)
-	case "/receipt":
-		handleReceipt(client, cmd)
-	default:
-		log.Printf("Unknown command: %s", cmd.Command)
-	}
-}
-
-
-
-func handleReceipt(client *slack.Client, cmd slack.SlashCommand) {
-	channelID := os.Getenv("CHANNEL_ID")
-
-	historyParams := slack.GetConversationHistoryParameters{
-		ChannelID: channelID,
-		Limit:     10,
-	}
-
-	history, err := client.GetConversationHistory(&historyParams)
-	if err != nil {
-		postMessage(client, cmd.ChannelID, "Error getting conversation history.")
-		return
-	}
-
-	// Find the latest image file
-	var latestFile *slack.File
-	for _, msg := range history.Messages {
-		if len(msg.Files) > 0 {
-			file := msg.Files[0] // Get the first file from the message
-			if file.Mimetype == "image/png" || file.Mimetype == "image/jpeg" { // Check for image type
-				latestFile = &file // Assign the file pointer to latestFile
-				break
+func (b *Bot) handleInteraction(callback slack.InteractionCallback) {
+	switch callback.Type {
+	case slack.InteractionTypeBlockActions:
+		for _, action := range callback.ActionCallback.BlockActions {
+			switch action.ActionID {
+			case feedbackOpenAction:
+				b.openFeedbackSurvey(callback, action.Value)
+			case tuneApplyAction:
+				b.handleTuneApply(callback, action.Value)
+			case receiptConfirmAction, receiptDiscardAction:
+				b.handleReceiptAction(callback, action.ActionID, action.Value)
+			default:
+				slog.Warn("Unknown block action", "action", action.ActionID)
 			}
 		}
+	case slack.InteractionTypeViewSubmission:
+		switch callback.View.CallbackID {
+		case feedbackCallbackID:
+			b.handleFeedbackSubmission(callback)
+		default:
+			slog.Warn("Unknown view submission", "callback", callback.View.CallbackID)
+		}
 	}
+}
 
-	if latestFile == nil {
-		postMessage(client, cmd.ChannelID, "No image found in the last 10 messages.")
-		return
-	}
-
-	// Download the image with proper authentication
-	req, err := http.NewRequest("GET", latestFile.URLPrivateDownload, nil)
-	if err != nil {
-		postMessage(client, cmd.ChannelID, "Error creating request.")
-		return
-	}
-	req.Header.Add("Authorization", "Bearer "+os.Getenv("SLACK_BOT_TOKEN"))
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		postMessage(client, cmd.ChannelID, "Error downloading image.")
-		return
-	}
-	defer resp.Body.Close()
 
-	// Read the image data
-	imageData, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		postMessage(client, cmd.ChannelID, "Error reading image data.")
-		return
-	}
 
-	// Save the image locally
-	fileName := "./images/" + latestFile.Name
-	err = ioutil.WriteFile(fileName, imageData, 0644)
-	if err != nil {
-		postMessage(client, cmd.ChannelID, "Error saving image locally.")
+func (b *Bot) handleStart(cmd slack.SlashCommand) {
+	args := strings.Fields(cmd.Text)
+	if len(args) < 1 {
+		b.replyEphemeral(cmd, "Please specify the deadline time (in format HH:MM).")
 		return
 	}
 
-	fmt.Printf("Image saved locally as: %s\n", fileName)
-	postMessage(client, cmd.ChannelID, "Image saved locally.")
-
-	description, err := getDescriptionFromAPI(fileName)
+	timeArg := args[0]
+	deadline, err := time.Parse("15:04", timeArg)
 	if err != nil {
-		postMessage(client, cmd.ChannelID, "Error getting image description from API.")
+		b.replyEphemeral(cmd, "Invalid time format. Please use HH:MM format.")
 		return
 	}
 
-	postMessage(client, cmd.ChannelID, "Image Description: "+description)
+	now := b.localNow()
+	sessionDeadline := time.Date(now.Year(), now.Month(), now.Day(), deadline.Hour(), deadline.Minute(), 0, 0, now.Location())
+	suggest := (len(args) > 1 && args[1] == "suggest") || b.config().StartRecommendations
+	b.startSession(cmd.ChannelID, sessionDeadline, cmd.UserID, suggest)
 }
 
-func getDescriptionFromAPI(imagePath string) (string, error) {
-	apiURL := "http://127.0.0.1:5000/describe-image"
-
-	// Create the JSON payload
-	payload := map[string]string{"image_path": imagePath}
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("error marshaling JSON payload: %w", err)
-	}
-
-	// Send the POST request
-	resp, err := http.Post(apiURL, "application/json", bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return "", fmt.Errorf("error sending POST request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read the response body
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error reading response body: %w", err)
-	}
-
-	// Parse the JSON response
-	var responseData map[string]string
-	err = json.Unmarshal(respBody, &responseData)
-	if err != nil {
-		return "", fmt.Errorf("error unmarshaling JSON response: %w", err)
-	}
-
-	// Check for an error in the response
-	if errorMsg, exists := responseData["error"]; exists {
-		return "", fmt.Errorf("API error: %s", errorMsg)
-	}
-
-	// Return the description from the response
-	return responseData["description"], nil
+// startSession opens a new order session in channelID, replacing the open one. startedBy is empty for
+// scheduled sessions.
+func (b *Bot) startSession(channelID string, deadline time.Time, startedBy string, suggest bool) {
+	session := b.newSessionRecord(channelID, deadline, startedBy)
+	b.session.open(session, PriorityQueue{}, true)
+	b.announceSession(session, suggest)
 }
 
+// announceSession tells the channel a session opened and watches its deadline.
+func (b *Bot) announceSession(session SessionRecord, suggest bool) {
+	response := fmt.Sprintf("Order session started <!here> . You can place orders until %s.", session.Deadline.Format("15:04"))
+	postMessage(b.poster, session.ChannelID, response)
 
-func detectImage(imagePath string) (string, error) {
-	var model = "gpt-4o"
-	var apiKey = os.Getenv("OPENAI_API_KEY")
-	var temperature float32 = 0.0
-
-	client := openai.NewClient(apiKey)
-
-	base64Image, err := encodeImage64(imagePath)
-	if err != nil {
-		log.Fatalf("Failed to encode image: %v", err)
-	}
-
-	messages := []openai.ChatCompletionMessage{
-		{Role: "system", Content: "You are a helpful assistant. Describe the image in one sentence."},
-		{Role: "user", Content: fmt.Sprintf(`{"type": "text", "text": "Describe the image."},
-                                             {"type": "image_url", "image_url": {"url": "data:image/png;base64,%s"}}`, base64Image)},
-	}
-
-	ctx := context.Background()
-
-	response, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:       model,
-		Messages:    messages,
-		Temperature: temperature,
-	})
-
-	if err != nil {
-		log.Fatalf("ChatCompletion error: %v", err)
+	if suggest {
+		b.postShoppingRecommendation(session.ChannelID)
 	}
 
-	return response.Choices[0].Message.Content, nil
+	go b.watchDeadline(session)
 }
 
-func encodeImage64(imagePath string) (string, error) {
-	imageFile, err := os.Open(imagePath)
-	if err != nil {
-		return "", err
-	}
-	defer imageFile.Close()
-
-	imageData, err := ioutil.ReadAll(imageFile)
-	if err != nil {
-		return "", err
+// watchDeadline reminds the channel before the deadline and summarizes the orders when it passes. A session
+// still open when the bot shuts down is handed off to the next run instead, one replaced by a newer
+// session is left alone.
+func (b *Bot) watchDeadline(session SessionRecord) {
+	// The offset is read when the session starts, a changed REMINDER_OFFSET applies to the next one.
+	offset := b.config().ReminderOffset
+	if left := session.Deadline.Sub(b.clock.Now()); offset > 0 && left > offset {
+		<-b.clock.After(left - offset)
+		reminded := b.handleInFlight(func() {
+			if b.session.isOpen(session.Started) {
+				postMessage(b.poster, session.ChannelID, fmt.Sprintf("<!here> %s left to place your orders.", formatReminderOffset(offset)))
+			}
+		})
+		if !reminded {
+			return
+		}
 	}
-
-	return base64.StdEncoding.EncodeToString(imageData), nil
+	<-b.clock.After(session.Deadline.Sub(b.clock.Now()))
+	b.handleInFlight(func() { b.summarizeOrders(session.Started) })
 }
 
-
-func handleStart(client *slack.Client, cmd slack.SlashCommand) {
-	args := strings.Fields(cmd.Text)
-	if len(args) < 1 {
-		postMessage(client, cmd.ChannelID, "Please specify the deadline time (in format HH:MM).")
-		return
-	}
-
-	timeArg := args[0]
-	deadline, err := time.Parse("15:04", timeArg)
-	if err != nil {
-		postMessage(client, cmd.ChannelID, "Invalid time format. Please use HH:MM format.")
-		return
+// formatReminderOffset writes the offset the way people say it, e.g. "5 minutes" or "1 hour".
+func formatReminderOffset(offset time.Duration) string {
+	if offset%time.Hour == 0 {
+		if offset == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", offset/time.Hour)
 	}
-
-	now := time.Now()
-	orderDeadline = time.Date(now.Year(), now.Month(), now.Day(), deadline.Hour(), deadline.Minute(), 0, 0, now.Location())
-	ordersEnabled = true
-	orderQueue = PriorityQueue{}
-
-	response := fmt.Sprintf("Order session started <!here> . You can place orders until %s.", orderDeadline.Format("15:04"))
-	postMessage(client, cmd.ChannelID, response)
-
-	go func() {
-		timeUntilDeadline := time.Until(orderDeadline)
-		if timeUntilDeadline > 5*time.Minute {
-			time.Sleep(timeUntilDeadline - 5*time.Minute)
-			postMessage(client, cmd.ChannelID, "<!here> 5 minutes left to place your orders.")
+	if offset%time.Minute == 0 {
+		if offset == time.Minute {
+			return "1 minute"
 		}
-		time.Sleep(5 * time.Minute)
-		summarizeOrders(client, cmd.ChannelID)
-	}()
+		return fmt.Sprintf("%d minutes", offset/time.Minute)
+	}
+	return offset.String()
 }
 
-func handleOrder(client *slack.Client, cmd slack.SlashCommand) {
-	if !ordersEnabled {
-		postMessage(client, cmd.ChannelID, "Orders are not enabled. Start a new session with /start {time}.")
+func (b *Bot) handleOrder(cmd slack.SlashCommand) {
+	if _, ok := b.session.snapshot(); !ok {
+		b.replyEphemeral(cmd, "Orders are not enabled. Start a new session with /start {time}.")
 		return
 	}
 
 	args := strings.Fields(cmd.Text)
 	if len(args) < 2 {
-		postMessage(client, cmd.ChannelID, "Please specify the item and quantity.")
+		b.replyEphemeral(cmd, "Please specify the item and quantity.")
 		return
 	}
 
 	item := args[0]
 	quantity, err := strconv.Atoi(args[1])
 	if err != nil {
-		postMessage(client, cmd.ChannelID, "Invalid quantity. Please enter a number.")
+		b.replyEphemeral(cmd, "Invalid quantity. Please enter a number.")
 		return
 	}
 
-	itemID := getItemID(item)
+	itemData := b.menu.Items()
+	item = menuItemName(itemData, item)
+	itemID := b.menu.ItemID(item)
 	if itemID == "" {
-		postMessage(client, cmd.ChannelID, "Item not found.")
+		var names []string
+		for name := range itemData {
+			names = append(names, name)
+		}
+		b.replyEphemeral(cmd, fmt.Sprintf("%s is not on the menu, see /menu.%s", item, didYouMean(item, names, "/order %s "+args[1])))
 		return
 	}
 
 	// sendOrder(order)
 
-	response := fmt.Sprintf("Order placed: %s %d", item, quantity)
-	postMessage(client, cmd.ChannelID, response)
-
-	itemData := fetchItemData()
 	itemInfo, ok := itemData[item]
 	if !ok {
-		postMessage(client, cmd.ChannelID, "Failed to fetch item data.")
+		b.replyError(cmd, "Failed to fetch item data.", nil)
 		return
 	}
 	cookTime := calculateCookingTime(quantity, itemInfo.CapacityOnGrill, itemInfo.SecondsToCook)
 	newOrder := &Order{
+		User:     cmd.UserID,
 		Item:     item,
 		Quantity: quantity,
 		CookTime: cookTime,
 	}
 
-	heap.Push(&orderQueue, newOrder)
-}
-
-func fetchItemData() map[string]ItemInfo {
-	url := os.Getenv("SERVER_ITEM")
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		return nil
-	}
-
-	req.Header.Add("Authorization", "Bearer "+os.Getenv("BEARER_TOKEN"))
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send request: %v", err)
-		return nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Error response from server: %v", resp.Status)
-		return nil
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
-		return nil
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("Failed to parse response body: %v", err)
-		return nil
-	}
-
-	itemData := make(map[string]ItemInfo)
-	if response, ok := result["response"].(map[string]interface{}); ok {
-		if results, ok := response["results"].([]interface{}); ok {
-			for _, res := range results {
-				if record, ok := res.(map[string]interface{}); ok {
-					itemName, _ := record["item name"].(string)
-					secondsToCook, _ := record["seconds to cook"].(float64)
-					capacityOnGrill, _ := record["capacity on grill"].(float64)
-					itemData[itemName] = ItemInfo{
-						ItemName:        itemName,
-						SecondsToCook:   int(secondsToCook),
-						CapacityOnGrill: int(capacityOnGrill),
-					}
-				}
-			}
-		}
+	if !b.session.addOrder(newOrder) {
+		b.replyEphemeral(cmd, "The order session just closed. Start a new session with /start {time}.")
+		return
 	}
 
-	return itemData
+	response := fmt.Sprintf("Order placed: %s %d", item, quantity)
+	postMessage(b.poster, cmd.ChannelID, response)
 }
 
-func handleMenu(client *slack.Client, cmd slack.SlashCommand) {
-	url := os.Getenv("SERVER_ITEM")
-		if url == "" {
-			log.Printf("SERVER_ITEM environment variable is not set")
-			postMessage(client, cmd.ChannelID, "Failed to add the item.")
-			return
-		}
+func (b *Bot) handleMenu(cmd slack.SlashCommand) {
 	args := strings.Fields(cmd.Text)
 
 	if len(args) > 0 && args[0] == "add" && len(args) > 4 {
@@ -474,95 +343,56 @@ func handleMenu(client *slack.Client, cmd slack.SlashCommand) {
 			"price":            price,
 			"seconds to cook":  secondsToCook,
 		}
-
-		newItemJSON, err := json.Marshal(newItem)
-		if err != nil {
-			log.Printf("Failed to marshal new item: %v", err)
-			postMessage(client, cmd.ChannelID, "Failed to add the item.")
-			return
-		}
-
-		req, err := http.NewRequest("POST", url, strings.NewReader(string(newItemJSON)))
-		if err != nil {
-			log.Printf("Failed to create request: %v", err)
-			postMessage(client, cmd.ChannelID, "Failed to add the item.")
-			return
+		if len(args) > 5 {
+			newItem["store section"] = strings.Join(args[5:], " ")
 		}
 
-		req.Header.Add("Authorization", "Bearer "+os.Getenv("BEARER_TOKEN"))
-		req.Header.Add("Content-Type", "application/json")
-
-		httpClient := &http.Client{}
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			log.Printf("Failed to send request: %v", err)
-			postMessage(client, cmd.ChannelID, "Failed to add the item.")
-			return
-		}
-		defer resp.Body.Close()
-		
-		if resp.StatusCode != http.StatusCreated {
-			log.Printf("Error response from server: %v", resp.Status)
-			postMessage(client, cmd.ChannelID, "Failed to add the item.")
+		if err := b.menu.AddItem(newItem); err != nil {
+			b.replyError(cmd, "Failed to add the item.", err)
 			return
 		}
 
-		postMessage(client, cmd.ChannelID, "Successfully added item: "+item)
+		postMessage(b.poster, cmd.ChannelID, "Successfully added item: "+item)
 		return
 	}
 
 	// Handle fetching and displaying the menu
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Printf("Failed to send request: %v", err)
-		postMessage(client, cmd.ChannelID, "Failed to fetch the menu.")
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Error response from server: %v", resp.Status)
-		postMessage(client, cmd.ChannelID, "Failed to fetch the menu.")
-		return
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
-		postMessage(client, cmd.ChannelID, "Failed to fetch the menu.")
-		return
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("Failed to parse response body: %v", err)
-		postMessage(client, cmd.ChannelID, "Failed to fetch the menu.")
+	itemData := b.menu.Items()
+	if itemData == nil {
+		b.replyError(cmd, "Failed to fetch the menu.", nil)
 		return
 	}
 
 	var menuItems []string
-	if response, ok := result["response"].(map[string]interface{}); ok {
-		if results, ok := response["results"].([]interface{}); ok {
-			for _, res := range results {
-				if record, ok := res.(map[string]interface{}); ok {
-					if itemName, ok := record["item name"].(string); ok {
-						menuItems = append(menuItems, itemName)
-					}
-				}
-			}
-		}
+	for name := range itemData {
+		menuItems = append(menuItems, name)
 	}
+	sort.Strings(menuItems)
 
 	if len(menuItems) == 0 {
-		postMessage(client, cmd.ChannelID, "No items found in the menu.")
+		postMessage(b.poster, cmd.ChannelID, "No items found in the menu.")
 		return
 	}
 
 	menuMessage := "Here is the menu:\n" + strings.Join(menuItems, "\n")
-	postMessage(client, cmd.ChannelID, menuMessage)
+	postMessage(b.poster, cmd.ChannelID, menuMessage)
 }
 
 
+// menuItemName spells item the way the menu does, so "Kebapche" and "kebapche" order the same item. An
+// item that isn't on the menu is returned as it is.
+func menuItemName(itemData map[string]ItemInfo, item string) string {
+	if _, ok := itemData[item]; ok {
+		return item
+	}
+	for name := range itemData {
+		if strings.EqualFold(name, item) {
+			return name
+		}
+	}
+	return item
+}
+
 func calculateCookingTime(quantity, capacity, baseTime int) int {
 	batches := quantity / capacity
 	if quantity%capacity != 0 {
@@ -571,24 +401,27 @@ func calculateCookingTime(quantity, capacity, baseTime int) int {
 	return batches * baseTime
 }
 
-func summarizeOrders(client *slack.Client, channelID string) {
-	ordersEnabled = false
-	if len(orderQueue) == 0 {
-		postMessage(client, channelID, "No orders were placed.")
+// summarizeOrders closes the session that started at started and posts what was ordered. It does nothing
+// if that session is no longer open.
+func (b *Bot) summarizeOrders(started time.Time) {
+	session, ok := b.session.close(started)
+	if !ok {
+		return
+	}
+	channelID := session.ChannelID
+	if len(session.Orders) == 0 {
+		postMessage(b.poster, channelID, "No orders were placed.")
 		return
 	}
 
-	itemData := fetchItemData()
+	itemData := b.menu.Items()
+	session = b.recordClosedSession(session, itemData)
 	if itemData == nil {
-		postMessage(client, channelID, "Failed to fetch item data.")
+		postMessage(b.poster, channelID, "Failed to fetch item data.")
 		return
 	}
 
-	orderMap := make(map[string]int)
-	for orderQueue.Len() > 0 {
-		order := heap.Pop(&orderQueue).(*Order)
-		orderMap[order.Item] += order.Quantity
-	}
+	orderMap := session.ItemQuantities()
 
 	var summaryBuilder strings.Builder
 	summaryBuilder.WriteString("You have collectively ordered:\n")
@@ -607,269 +440,26 @@ func summarizeOrders(client *slack.Client, channelID string) {
 		counter++
 
 		orderSummary := map[string]interface{}{
-			"item ordered":    getItemID(item),
+			"item ordered":    b.menu.ItemID(item),
 			"seconds to cook": totalCookingTime,
 			"summed quantity": quantity,
 		}
-		sendOrderSummary(orderSummary)
+		b.orders.SendOrderSummary(orderSummary)
 	}
 
 	summaryBuilder.WriteString("The order won't be received now - start a new order session with /start {time}.")
 
-	postMessage(client, channelID, summaryBuilder.String())
-
-	recentOrders := fetchRecentOrders()
-	log.Printf("Recent: %s", recentOrders)
-	sendRecentOrders(recentOrders)
-}
-
-func sendOrderSummary(orderSummary map[string]interface{}) {
-	client := &http.Client{}
-	url := os.Getenv("SERVER_ORDER")
-
-	orderSummaryJSON, err := json.Marshal(orderSummary)
-	if err != nil {
-		log.Printf("Failed to marshal order summary: %v", err)
-		return
-	}
-
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(orderSummaryJSON)))
-	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		return
-	}
-
-	req.Header.Add("Authorization", "Bearer "+os.Getenv("BEARER_TOKEN"))
-	req.Header.Add("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send request: %v", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Error response from server: %v", resp.Status)
-		return
-	}
-
-	log.Println("Order summary sent successfully")
-}
+	postMessage(b.poster, channelID, summaryBuilder.String())
 
+	recentOrders := b.orders.RecentOrders()
+	slog.Debug("Recent orders", "orders", recentOrders)
+	b.orders.SendRecentOrders(recentOrders)
 
-func fetchRecentOrders() []string {
-	client := &http.Client{}
-	url := os.Getenv("SERVER_ORDER")
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		return nil
-	}
-
-	req.Header.Add("Authorization", "Bearer "+os.Getenv("BEARER_TOKEN"))
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send request: %v", err)
-		return nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Error response from server: %v", resp.Status)
-		return nil
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
-		return nil
-	}
-
-	var response struct {
-		Response struct {
-			Results []struct {
-				CreatedDate   string `json:"Created Date"`
-				ItemOrdered   string `json:"item ordered"`
-				SecondsToCook int    `json:"seconds to cook"`
-				SummedQuantity int   `json:"summed quantity"`
-				ID            string `json:"_id"`
-			} `json:"results"`
-		} `json:"response"`
-	}
-
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		log.Printf("Failed to unmarshal response: %v", err)
-		return nil
-	}
-
-	oneHourAgo := time.Now().Add(-1 * time.Hour)
-	var recentOrders []string
-	for _, order := range response.Response.Results {
-		createdDate, err := time.Parse(time.RFC3339, order.CreatedDate)
-		if err != nil {
-			log.Printf("Failed to parse date: %v", err)
-			continue
-		}
-		if createdDate.After(oneHourAgo) {
-			recentOrders = append(recentOrders, order.ID)
-		}
-	}
-
-	return recentOrders
-}
-
-func sendRecentOrders(orders []string) {
-	if len(orders) == 0 {
-		log.Println("No recent orders to send")
-		return
-	}
-
-	client := &http.Client{}
-	url := os.Getenv("SERVER_FULL_ORDER")
-
-    orderData := map[string]interface{}{
-        "orders": orders,
-    }
-
-    orderDataJSON, err := json.Marshal(orderData)
-    if err != nil {
-        log.Printf("Failed to marshal order data: %v", err)
-        return
-    }
-
-    req, err := http.NewRequest("POST", url, bytes.NewBuffer(orderDataJSON))
-    if err != nil {
-        log.Printf("Failed to create request: %v", err)
-        return
-    }
-
-    req.Header.Add("Authorization", "Bearer "+os.Getenv("BEARER_TOKEN"))
-    req.Header.Add("Content-Type", "application/json")
-
-    resp, err := client.Do(req)
-    if err != nil {
-        log.Printf("Failed to send request: %v", err)
-        return
-    }
-    defer resp.Body.Close()
-
-    // Read and log the response body
-    body, err := ioutil.ReadAll(resp.Body)
-    if err != nil {
-        log.Printf("Failed to read response body: %v", err)
-        return
-    }
-
-    if resp.StatusCode != http.StatusOK {
-        log.Printf("Error response from server: %v", resp.Status)
-        log.Printf("Response body: %s", string(body))
-        return
-    }
-
-    log.Println("Recent orders sent successfully")
-}
-
-
-func getUserID(userName string) string {
-	return getIDFromServer(os.Getenv("SERVER_USERS"), userName, "name")
-}
-
-func getItemID(itemName string) string {
-	return getIDFromServer(os.Getenv("SERVER_ITEM"), itemName, "item name")
-}
-
-func getIDFromServer(url, searchValue, searchKey string) string {
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		return ""
-	}
-
-	req.Header.Add("Authorization", "Bearer "+os.Getenv("BEARER_TOKEN"))
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send request: %v", err)
-		return ""
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Error response from server: %v", resp.Status)
-		return ""
-	}
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
-		return ""
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("Failed to parse response body: %v", err)
-		return ""
-	}
-
-	if response, ok := result["response"].(map[string]interface{}); ok {
-		if results, ok := response["results"].([]interface{}); ok {
-			for _, res := range results {
-				if record, ok := res.(map[string]interface{}); ok {
-					if name, ok := record[searchKey].(string); ok && name == searchValue {
-						if id, ok := record["_id"].(string); ok {
-							return id
-						}
-					}
-				}
-			}
-		}
-	}
-
-	log.Println("ID not found in response")
-	return ""
-}
-
-func sendOrder(order map[string]interface{}) {
-	client := &http.Client{}
-	url := os.Getenv("SERVER_ORDER")
-
-	orderJSON, err := json.Marshal(order)
-	if err != nil {
-		log.Printf("Failed to marshal order: %v", err)
-		return
-	}
-
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(orderJSON)))
-	if err != nil {
-		log.Printf("Failed to create request: %v", err)
-		return
-	}
-
-	req.Header.Add("Authorization", "Bearer "+os.Getenv("BEARER_TOKEN"))
-	req.Header.Add("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Failed to send request: %v", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Error response from server: %v", resp.Status)
-		return
-	}
-
-	log.Println("Order sent successfully")
+	b.sendFeedbackSurveys(session)
 }
 
-func postMessage(client *slack.Client, channelID, message string) {
-	if _, _, err := client.PostMessage(channelID, slack.MsgOptionText(message, false)); err != nil {
-		log.Printf("Failed to post message: %v", err)
+func postMessage(poster MessagePoster, channelID, message string) {
+	if _, _, err := poster.PostMessage(channelID, slack.MsgOptionText(message, false)); err != nil {
+		slog.Error("Failed to post message", "err", err)
 	}
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+const (
+	defaultMetricsAddr = ":9090"
+	readinessTimeout   = 5 * time.Second
+)
+
+var (
+	commandsHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "slack_bot_commands_total",
+		Help: "Slash commands handled, by command and outcome (ok, error, invalid, denied, unknown).",
+	}, []string{"command", "outcome"})
+
+	backendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "slack_bot_backend_request_duration_seconds",
+		Help:    "Latency of requests to the Bubble backend, by endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	backendErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "slack_bot_backend_request_errors_total",
+		Help: "Requests to the Bubble backend that failed or returned an error status, by endpoint.",
+	}, []string{"endpoint"})
+
+	sessionOrders = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "slack_bot_session_orders",
+		Help:    "Orders placed in each closed session.",
+		Buckets: []float64{0, 1, 2, 5, 10, 15, 20, 30, 50},
+	})
+
+	// activeSessions and openSessionOrders are kept up to date by orderSession.
+	activeSessions = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "slack_bot_active_sessions",
+		Help: "Order sessions open right now.",
+	})
+
+	openSessionOrders = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "slack_bot_open_session_orders",
+		Help: "Orders placed so far in the open session.",
+	})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "slack_bot_socket_connected",
+		Help: "1 while the socket mode connection to Slack is up.",
+	}, func() float64 {
+		if socketConnected.Load() {
+			return 1
+		}
+		return 0
+	})
+)
+
+// socketConnected follows the socket mode connection events, /healthz reports it.
+var socketConnected atomic.Bool
+
+// commandFailures holds the correlation IDs of the commands being handled, set to true once replyError
+// is called for one, so the outcome can be counted when the handler returns.
+var commandFailures sync.Map
+
+// instrumentedTransport records the latency and failures of each request by backend endpoint.
+type instrumentedTransport struct {
+	next     http.RoundTripper
+	settings *atomic.Pointer[Config]
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := backendEndpoint(req.URL.String(), t.settings.Load())
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	backendDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode >= 400 {
+		backendErrors.WithLabelValues(endpoint).Inc()
+	}
+	return resp, err
+}
+
+// backendEndpoint names the configured endpoint a request URL belongs to, e.g. server_item, so record IDs
+// and query strings don't end up in the labels.
+func backendEndpoint(requestURL string, cfg *Config) string {
+	endpoints := map[string]string{
+		"server_item":            cfg.ServerItem,
+		"server_order":           cfg.ServerOrder,
+		"server_full_order":      cfg.ServerFullOrder,
+		"server_users":           cfg.ServerUsers,
+		"server_grill":           cfg.ServerGrill,
+		"server_grill_status":    cfg.ServerGrillStatus,
+		"server_grill_status_wf": cfg.ServerGrillStatusWF,
+	}
+	// The longest matching URL wins, .../grill is a prefix of .../grill_status.
+	names := make([]string, 0, len(endpoints))
+	for name, endpointURL := range endpoints {
+		if endpointURL != "" && strings.HasPrefix(requestURL, endpointURL) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "other"
+	}
+	sort.Slice(names, func(i, j int) bool { return len(endpoints[names[i]]) > len(endpoints[names[j]]) })
+	return names[0]
+}
+
+// countCommand runs handler and counts it as ok, or as error when it replied with replyError.
+func countCommand(name string, cmd slack.SlashCommand, handler func()) {
+	id := correlationID(cmd)
+	commandFailures.Store(id, false)
+	handler()
+	outcome := "ok"
+	if failed, _ := commandFailures.LoadAndDelete(id); failed == true {
+		outcome = "error"
+	}
+	commandsHandled.WithLabelValues(name, outcome).Inc()
+}
+
+func markCommandFailed(cmd slack.SlashCommand) {
+	id := correlationID(cmd)
+	if _, handling := commandFailures.Load(id); handling {
+		commandFailures.Store(id, true)
+	}
+}
+
+// trackSocketConnection updates the connection state from a socket mode event.
+func trackSocketConnection(eventType socketmode.EventType) {
+	switch eventType {
+	case socketmode.EventTypeConnected:
+		socketConnected.Store(true)
+	case socketmode.EventTypeConnecting, socketmode.EventTypeConnectionError,
+		socketmode.EventTypeInvalidAuth, socketmode.EventTypeDisconnect:
+		socketConnected.Store(false)
+	}
+}
+
+// startMetricsServer serves /healthz, /readyz and /metrics on addr, /readyz asks ready. It returns nil
+// when addr is empty.
+func startMetricsServer(addr string, ready func(ctx context.Context) error) *http.Server {
+	if addr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !socketConnected.Load() {
+			http.Error(w, "not connected to Slack", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := ready(r.Context()); err != nil {
+			http.Error(w, "backend not reachable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		slog.Info("Metrics server listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics server stopped", "err", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Receipt is what the describer read from a shop receipt.
+type Receipt struct {
+	Store    string        `json:"store"`
+	Date     string        `json:"date"`
+	Currency string        `json:"currency"`
+	Items    []ReceiptItem `json:"items"`
+	Total    float64       `json:"total"`
+}
+
+type ReceiptItem struct {
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Total     float64 `json:"total"`
+}
+
+// Expense is a confirmed receipt, paid by one user for a session.
+type Expense struct {
+	ID       string    `json:"id"`
+	PaidBy   string    `json:"paid_by"`
+	Receipt  Receipt   `json:"receipt"`
+	Recorded time.Time `json:"recorded"`
+}
+
+type pendingReceipt struct {
+	Receipt     Receipt
+	RequestedBy string
+	Created     time.Time
+}
+
+const (
+	receiptConfirmAction = "receipt_confirm"
+	receiptDiscardAction = "receipt_discard"
+	// A receipt confirmed after the session closed still belongs to it for this long.
+	expenseSessionWindow = 12 * time.Hour
+	// A receipt that wasn't confirmed or discarded within this long is dropped.
+	pendingReceiptTTL = 24 * time.Hour
+
+	receiptPrompt = `Read this shop receipt. Reply with JSON only, without any other text, in this form:
+{"store": "", "date": "YYYY-MM-DD", "currency": "", "items": [{"name": "", "quantity": 1, "unit_price": 0, "total": 0}], "total": 0}
+Use numbers for quantities and prices and leave out what can't be read.`
+)
+
+// Sum adds up the line items.
+func (r Receipt) Sum() float64 {
+	sum := 0.0
+	for _, item := range r.Items {
+		sum += item.Total
+	}
+	return sum
+}
+
+// readReceipt asks the image describer for the receipt as JSON and checks the result.
+func (b *Bot) readReceipt(data []byte, mimeType string) (Receipt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+	text, err := b.describer.DescribeImage(ctx, data, mimeType, receiptPrompt)
+	if err != nil {
+		return Receipt{}, err
+	}
+	return parseReceipt(text)
+}
+
+func parseReceipt(text string) (Receipt, error) {
+	// Models like to wrap JSON in code fences or a sentence, so only the object is parsed.
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return Receipt{}, fmt.Errorf("no receipt data in %q", text)
+	}
+
+	var receipt Receipt
+	if err := json.Unmarshal([]byte(text[start:end+1]), &receipt); err != nil {
+		return Receipt{}, fmt.Errorf("error parsing receipt: %w", err)
+	}
+
+	var items []ReceiptItem
+	for _, item := range receipt.Items {
+		item.Name = strings.TrimSpace(item.Name)
+		if item.Name == "" {
+			continue
+		}
+		if item.Quantity <= 0 {
+			item.Quantity = 1
+		}
+		if item.Total == 0 {
+			item.Total = roundMoney(item.Quantity * item.UnitPrice)
+		}
+		if item.UnitPrice == 0 {
+			item.UnitPrice = roundMoney(item.Total / item.Quantity)
+		}
+		items = append(items, item)
+	}
+	receipt.Items = items
+	if receipt.Total == 0 {
+		receipt.Total = roundMoney(receipt.Sum())
+	}
+	if len(receipt.Items) == 0 && receipt.Total == 0 {
+		return Receipt{}, fmt.Errorf("receipt has no items or total")
+	}
+	return receipt, nil
+}
+
+func roundMoney(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func formatMoney(value float64, currency string) string {
+	if currency == "" {
+		return fmt.Sprintf("%.2f", value)
+	}
+	return fmt.Sprintf("%.2f %s", value, currency)
+}
+
+func formatReceipt(receipt Receipt) string {
+	var builder strings.Builder
+	store := receipt.Store
+	if store == "" {
+		store = "Unknown store"
+	}
+	builder.WriteString(fmt.Sprintf("*%s*", store))
+	if receipt.Date != "" {
+		builder.WriteString(" — " + receipt.Date)
+	}
+	builder.WriteString("\n")
+	for _, item := range receipt.Items {
+		builder.WriteString(fmt.Sprintf("• %s: %g x %s = %s\n", item.Name, item.Quantity,
+			formatMoney(item.UnitPrice, ""), formatMoney(item.Total, receipt.Currency)))
+	}
+	builder.WriteString(fmt.Sprintf("*Total: %s*", formatMoney(receipt.Total, receipt.Currency)))
+	if len(receipt.Items) > 0 && math.Abs(receipt.Sum()-receipt.Total) >= 0.01 {
+		builder.WriteString(fmt.Sprintf("\n:warning: The items add up to %s, please check the receipt.", formatMoney(receipt.Sum(), receipt.Currency)))
+	}
+	return builder.String()
+}
+
+// postReceiptConfirmation shows the receipt that was read with Confirm and Discard buttons.
+func (b *Bot) postReceiptConfirmation(cmd slack.SlashCommand, receipt Receipt) {
+	id := correlationID(cmd)
+	now := b.clock.Now()
+	b.pendingReceiptsMu.Lock()
+	if b.pendingReceipts == nil {
+		b.pendingReceipts = make(map[string]pendingReceipt)
+	}
+	for pendingID, pending := range b.pendingReceipts {
+		if now.Sub(pending.Created) > pendingReceiptTTL {
+			delete(b.pendingReceipts, pendingID)
+		}
+	}
+	b.pendingReceipts[id] = pendingReceipt{Receipt: receipt, RequestedBy: cmd.UserID, Created: now}
+	b.pendingReceiptsMu.Unlock()
+
+	text := "I read this receipt :receipt:\n" + formatReceipt(receipt)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(receiptConfirmAction, id,
+				slack.NewTextBlockObject(slack.PlainTextType, "Confirm as session expense", false, false)).WithStyle(slack.StylePrimary),
+			slack.NewButtonBlockElement(receiptDiscardAction, id,
+				slack.NewTextBlockObject(slack.PlainTextType, "Discard", false, false))),
+	}
+	if _, _, err := b.poster.PostMessage(cmd.ChannelID, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...)); err != nil {
+		slog.Error("Failed to post message", "err", err)
+	}
+}
+
+// handleReceiptAction records or drops a pending receipt. Only the user who ran /receipt or an admin may do so.
+func (b *Bot) handleReceiptAction(callback slack.InteractionCallback, actionID, id string) {
+	// The role store is read before taking the lock, other clicks shouldn't wait on the file.
+	admin, err := b.hasRole(callback.User.ID, roleAdmin)
+	if err != nil {
+		slog.Error("Failed to load roles", "err", err)
+	}
+
+	b.pendingReceiptsMu.Lock()
+	pending, ok := b.pendingReceipts[id]
+	allowed := ok && (pending.RequestedBy == callback.User.ID || admin)
+	if allowed {
+		delete(b.pendingReceipts, id)
+	}
+	b.pendingReceiptsMu.Unlock()
+
+	if !ok || !allowed {
+		text := "This receipt was already handled or has expired, run /receipt again."
+		if ok {
+			text = "Only the person who ran /receipt or an admin can confirm it."
+		}
+		if _, err := b.poster.PostEphemeral(callback.Channel.ID, callback.User.ID, slack.MsgOptionText(text, false)); err != nil {
+			slog.Error("Failed to post ephemeral message", "err", err)
+		}
+		return
+	}
+
+	text := "Receipt discarded.\n" + formatReceipt(pending.Receipt)
+	if actionID == receiptConfirmAction {
+		// Whoever ran /receipt paid, also when an admin confirms it for them.
+		expense := Expense{ID: id, PaidBy: pending.RequestedBy, Receipt: pending.Receipt, Recorded: b.clock.Now()}
+		session, err := b.recordExpense(expense)
+		switch {
+		case err != nil:
+			slog.Error("Failed to record expense", "err", err)
+			text = "Failed to record the receipt, please try /receipt again."
+		case session == "":
+			text = "There is no open or recent session to add the receipt to. Start one with /start {time} and run /receipt again."
+		default:
+			text = fmt.Sprintf("<@%s> paid %s for the session %s :moneybag: Type `/split` to see who owes what.\n%s",
+				pending.RequestedBy, formatMoney(pending.Receipt.Total, pending.Receipt.Currency), session, formatReceipt(pending.Receipt))
+		}
+	}
+
+	// Replace the buttons so the receipt can't be confirmed twice.
+	blocks := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)}
+	if _, _, _, err := b.poster.UpdateMessage(callback.Channel.ID, callback.Message.Timestamp,
+		slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...)); err != nil {
+		slog.Error("Failed to update message", "err", err)
+	}
+}
+
+// recordExpense adds the expense to the open session, or to the last one if it closed recently.
+// It returns a description of the session, empty when there was none.
+func (b *Bot) recordExpense(expense Expense) (string, error) {
+	if deadline, ok := b.session.addExpense(expense); ok {
+		return "open until " + deadline.Format("15:04"), nil
+	}
+
+	var description string
+	err := b.updateSessions(func(sessions []SessionRecord) []SessionRecord {
+		if len(sessions) == 0 || b.clock.Now().Sub(sessions[len(sessions)-1].Closed) > expenseSessionWindow {
+			return sessions
+		}
+		session := &sessions[len(sessions)-1]
+		session.Expenses = append(session.Expenses, expense)
+		description = "from " + session.Started.Format("Mon 02 Jan")
+		return sessions
+	})
+	return description, err
+}
+
+func (b *Bot) handleSplit(cmd slack.SlashCommand) {
+	session, ok := b.expenseSession()
+	if !ok {
+		b.replyEphemeral(cmd, "There are no receipts for the current or last session. Add one with /receipt.")
+		return
+	}
+	if len(session.Participants()) == 0 {
+		b.replyEphemeral(cmd, "Nobody ordered in the session, so there is nobody to split the costs between.")
+		return
+	}
+	postMessage(b.poster, cmd.ChannelID, formatCostSplit(session, splitCosts(session)))
+}
+
+// expenseSession is the open session if it has receipts, or else the last stored session with receipts.
+func (b *Bot) expenseSession() (SessionRecord, bool) {
+	if session, ok := b.session.snapshot(); ok && len(session.Expenses) > 0 {
+		return session, true
+	}
+
+	sessions, err := b.loadSessions()
+	if err != nil {
+		slog.Error("Failed to load sessions", "err", err)
+		return SessionRecord{}, false
+	}
+	for i := len(sessions) - 1; i >= 0; i-- {
+		if len(sessions[i].Expenses) > 0 {
+			return sessions[i], true
+		}
+	}
+	return SessionRecord{}, false
+}
+
+// splitCosts returns what each participant's share of the session's receipts is. Receipt items that match
+// an ordered menu item are split by the quantities ordered; everything else is split evenly.
+func splitCosts(session SessionRecord) map[string]float64 {
+	participants := session.Participants()
+	ordered := make(map[string]map[string]int)
+	var itemNames []string
+	for _, order := range session.Orders {
+		if ordered[order.Item] == nil {
+			ordered[order.Item] = make(map[string]int)
+			itemNames = append(itemNames, order.Item)
+		}
+		ordered[order.Item][order.User] += order.Quantity
+	}
+
+	shares := make(map[string]float64)
+	shared := 0.0
+	for _, expense := range session.Expenses {
+		matched := 0.0
+		for _, item := range expense.Receipt.Items {
+			name, ok := matchOrderedItem(item.Name, itemNames)
+			if !ok {
+				continue
+			}
+			total := 0
+			for _, quantity := range ordered[name] {
+				total += quantity
+			}
+			if total == 0 {
+				continue
+			}
+			for user, quantity := range ordered[name] {
+				shares[user] += item.Total * float64(quantity) / float64(total)
+			}
+			matched += item.Total
+		}
+		// Unmatched items, and whatever the items don't add up to (deposits, discounts), are shared.
+		shared += expense.Receipt.Total - matched
+	}
+
+	for _, user := range participants {
+		shares[user] += shared / float64(len(participants))
+	}
+	for user, share := range shares {
+		shares[user] = roundMoney(share)
+	}
+	return shares
+}
+
+// matchOrderedItem finds the ordered menu item a receipt line is for, e.g. "Kebapche 10 pcs" for kebapche.
+func matchOrderedItem(name string, items []string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, item := range items {
+		if strings.Contains(lower, strings.ToLower(item)) {
+			return item, true
+		}
+	}
+	for _, word := range strings.Fields(lower) {
+		if match, ok := closestMatch(word, items); ok && len(word) > 3 {
+			return match, true
+		}
+	}
+	return "", false
+}
+
+func formatCostSplit(session SessionRecord, shares map[string]float64) string {
+	paid := make(map[string]float64)
+	total := 0.0
+	currency := ""
+	for _, expense := range session.Expenses {
+		paid[expense.PaidBy] += expense.Receipt.Total
+		total += expense.Receipt.Total
+		if currency == "" {
+			currency = expense.Receipt.Currency
+		}
+	}
+
+	users := make(map[string]bool)
+	for user := range shares {
+		users[user] = true
+	}
+	for user := range paid {
+		users[user] = true
+	}
+	var sorted []string
+	for user := range users {
+		sorted = append(sorted, user)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return shares[sorted[i]] > shares[sorted[j]] })
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Cost split for the session from %s: %s in total :money_with_wings:\n",
+		session.Started.Format("Mon 02 Jan"), formatMoney(total, currency)))
+	for _, user := range sorted {
+		line := fmt.Sprintf("• <@%s>: share %s", user, formatMoney(shares[user], currency))
+		balance := roundMoney(paid[user] - shares[user])
+		switch {
+		case balance > 0:
+			line += fmt.Sprintf(", paid %s, gets back %s", formatMoney(paid[user], currency), formatMoney(balance, currency))
+		case balance < 0:
+			line += fmt.Sprintf(", owes %s", formatMoney(-balance, currency))
+		}
+		builder.WriteString(line + "\n")
+	}
+	return builder.String()
+}
+
+var (
+	errNotReceiptReference = errors.New("not a file ID or link")
+	fileIDPattern          = regexp.MustCompile(`^F[A-Z0-9]{6,}$`)
+	filePermalinkPattern   = regexp.MustCompile(`/files/[^/]+/(F[A-Z0-9]+)`)
+	messageLinkPattern     = regexp.MustCompile(`/archives/([A-Z0-9]+)/p(\d{10})(\d{6})`)
+)
+
+const (
+	receiptSearchLimit = 20
+	slackAPITimeout    = 30 * time.Second
+)
+
+// slackAPIClient makes the Slack API calls the slack library doesn't cover.
+var slackAPIClient = &http.Client{Timeout: slackAPITimeout}
+
+func (b *Bot) handleReceipt(cmd slack.SlashCommand) {
+	var file *slack.File
+	var err error
+	if args := strings.Fields(cmd.Text); len(args) > 0 {
+		file, err = b.findReceiptByReference(args[0])
+	} else {
+		file, err = b.findLatestReceipt(cmd.ChannelID, "")
+	}
+	if errors.Is(err, errNotReceiptReference) {
+		b.replyEphemeral(cmd, "Pass a file or message link, or a file ID: `/receipt [link]`")
+		return
+	}
+	if err != nil {
+		b.replyError(cmd, "Failed to look up the receipt.", err)
+		return
+	}
+	if file == nil {
+		b.replyEphemeral(cmd, fmt.Sprintf("No receipt photo or PDF found in the last %d messages. "+
+			"Upload one, or pass its link: `/receipt [link]`", receiptSearchLimit))
+		return
+	}
+
+	b.processReceiptFile(cmd, *file)
+}
+
+// processReceiptFile downloads the file, reads it and asks for confirmation.
+func (b *Bot) processReceiptFile(cmd slack.SlashCommand, file slack.File) {
+	attachment, err := b.downloadReceipt(file)
+	if err != nil {
+		b.replyError(cmd, "Error downloading the receipt.", err)
+		return
+	}
+
+	receipt, err := b.readReceipt(attachment.Data, attachment.MimeType)
+	if err != nil {
+		b.replyError(cmd, "Failed to read the receipt.", err)
+		return
+	}
+	b.postReceiptConfirmation(cmd, receipt)
+}
+
+// findReceiptByReference finds the receipt from a file ID, a file link, or a message link. For a message link
+// the message's thread is searched as well.
+func (b *Bot) findReceiptByReference(reference string) (*slack.File, error) {
+	// Slack sends links as <url> or <url|label>.
+	reference = strings.TrimSuffix(strings.TrimPrefix(reference, "<"), ">")
+	reference, _, _ = strings.Cut(reference, "|")
+
+	fileID := ""
+	if fileIDPattern.MatchString(reference) {
+		fileID = reference
+	} else if match := filePermalinkPattern.FindStringSubmatch(reference); match != nil {
+		fileID = match[1]
+	}
+	if fileID != "" {
+		file, _, _, err := b.files.GetFileInfo(fileID, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		if !isReceiptFile(*file) {
+			return nil, nil
+		}
+		return file, nil
+	}
+
+	match := messageLinkPattern.FindStringSubmatch(reference)
+	if match == nil {
+		return nil, errNotReceiptReference
+	}
+	timestamp := match[2] + "." + match[3]
+	if parsed, err := url.Parse(reference); err == nil && parsed.Query().Get("thread_ts") != "" {
+		timestamp = parsed.Query().Get("thread_ts")
+	}
+	return b.findLatestReceipt(match[1], timestamp)
+}
+
+// findLatestReceipt searches the newest messages of a channel, or of a thread when threadTS is set.
+func (b *Bot) findLatestReceipt(channelID, threadTS string) (*slack.File, error) {
+	var messages []slack.Message
+	if threadTS != "" {
+		replies, _, _, err := b.files.GetConversationReplies(&slack.GetConversationRepliesParameters{
+			ChannelID: channelID,
+			Timestamp: threadTS,
+			Limit:     receiptSearchLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Replies come oldest first.
+		for i := len(replies) - 1; i >= 0; i-- {
+			messages = append(messages, replies[i])
+		}
+	} else {
+		history, err := b.files.GetConversationHistory(&slack.GetConversationHistoryParameters{
+			ChannelID: channelID,
+			Limit:     receiptSearchLimit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		messages = history.Messages
+	}
+
+	for _, msg := range messages {
+		if file, ok := receiptFromFiles(msg.Files); ok {
+			return &file, nil
+		}
+	}
+	return nil, nil
+}
+
+// receiptFromFiles picks the receipt among the files of one message, preferring one named like a receipt.
+func receiptFromFiles(files []slack.File) (slack.File, bool) {
+	var found []slack.File
+	for _, file := range files {
+		if isReceiptFile(file) {
+			found = append(found, file)
+		}
+	}
+	if len(found) == 0 {
+		return slack.File{}, false
+	}
+	for _, file := range found {
+		name := strings.ToLower(file.Name + " " + file.Title)
+		if strings.Contains(name, "receipt") {
+			return file, true
+		}
+	}
+	return found[0], true
+}
+
+// isReceiptFile accepts images the describer reads directly, and HEIC photos and PDFs which are read from
+// the previews Slack renders for them.
+func isReceiptFile(file slack.File) bool {
+	switch file.Mimetype {
+	case "image/png", "image/jpeg", "image/webp", "image/gif", "image/heic", "image/heif", "application/pdf":
+		return true
+	}
+	return false
+}
+
+// downloadReceipt stores the receipt as an image the describer can read.
+func (b *Bot) downloadReceipt(file slack.File) (Attachment, error) {
+	downloadURL := file.URLPrivateDownload
+	switch file.Mimetype {
+	case "image/heic", "image/heif":
+		downloadURL = largestThumbnail(file)
+	case "application/pdf":
+		var err error
+		if downloadURL, err = b.pdfPreview(file.ID); err != nil {
+			return Attachment{}, err
+		}
+	default:
+		if int64(file.Size) > b.attachments.MaxBytes() {
+			return Attachment{}, fmt.Errorf("%s has %d bytes, over the limit of %d", file.Name, file.Size, b.attachments.MaxBytes())
+		}
+	}
+	if downloadURL == "" {
+		return Attachment{}, fmt.Errorf("no readable preview of %s (%s)", file.Name, file.Mimetype)
+	}
+
+	buf := &limitedBuffer{max: b.attachments.MaxBytes()}
+	if err := b.files.GetFile(downloadURL, buf); err != nil {
+		return Attachment{}, err
+	}
+	attachment, err := b.attachments.Save(buf.data)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if !strings.HasPrefix(attachment.MimeType, "image/") {
+		return Attachment{}, fmt.Errorf("%s is %s, not an image", file.Name, attachment.MimeType)
+	}
+	return attachment, nil
+}
+
+func largestThumbnail(file slack.File) string {
+	for _, thumb := range []string{file.Thumb1024, file.Thumb960, file.Thumb720, file.Thumb480, file.Thumb360} {
+		if thumb != "" {
+			return thumb
+		}
+	}
+	return ""
+}
+
+// pdfPreview finds an image of the PDF's first page: the largest thumbnail files.info returns, or
+// thumb_pdf when there is none.
+func (b *Bot) pdfPreview(fileID string) (string, error) {
+	file, _, _, err := b.files.GetFileInfo(fileID, 0, 0)
+	if err != nil {
+		return "", err
+	}
+	if thumb := largestThumbnail(*file); thumb != "" {
+		return thumb, nil
+	}
+	return b.pdfThumbnail(fileID)
+}
+
+// pdfThumbnail reads the thumb_pdf preview of the PDF's first page, which the slack library doesn't expose.
+func (b *Bot) pdfThumbnail(fileID string) (string, error) {
+	req, err := http.NewRequest("GET", "https://slack.com/api/files.info?file="+url.QueryEscape(fileID), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", "Bearer "+b.config().SlackBotToken)
+
+	resp, err := slackAPIClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		File  struct {
+			ThumbPDF string `json:"thumb_pdf"`
+		} `json:"file"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if !result.OK {
+		return "", fmt.Errorf("files.info: %s", result.Error)
+	}
+	return result.File.ThumbPDF, nil
+}
+
+// handleFileShared reads receipts uploaded to RECEIPT_CHANNEL_ID as soon as they are shared.
+func (b *Bot) handleFileShared(event *slackevents.FileSharedEvent) {
+	channelID := b.config().ReceiptChannelID
+	if channelID == "" || event.ChannelID != channelID || event.UserID == b.currentBotUserID() {
+		return
+	}
+
+	file, _, _, err := b.files.GetFileInfo(event.FileID, 0, 0)
+	if err != nil {
+		slog.Error("Failed to get info of shared file", "file", event.FileID, "err", err)
+		return
+	}
+	if !isReceiptFile(*file) {
+		return
+	}
+
+	b.processReceiptFile(slack.SlashCommand{
+		Command:   "/receipt",
+		UserID:    event.UserID,
+		ChannelID: event.ChannelID,
+		TriggerID: event.FileID,
+	}, *file)
+}
+
+// currentBotUserID is the bot's own user, so the charts it uploads aren't taken for receipts. It is
+// looked up again on the next call when AuthTest fails.
+func (b *Bot) currentBotUserID() string {
+	b.botUserMu.Lock()
+	defer b.botUserMu.Unlock()
+	if b.botUserID != "" {
+		return b.botUserID
+	}
+	auth, err := b.files.AuthTest()
+	if err != nil {
+		slog.Error("Failed to look up the bot user", "err", err)
+		return ""
+	}
+	b.botUserID = auth.UserID
+	return b.botUserID
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestPDFPreviewUsesFileInfoThumbnail(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.slack.addFile("U1", slack.File{
+		ID:       "F0RECEIPT1",
+		Name:     "receipt.pdf",
+		Mimetype: "application/pdf",
+		Thumb480: "https://files.slack.test/F0RECEIPT1/thumb_480.png",
+		Thumb720: "https://files.slack.test/F0RECEIPT1/thumb_720.png",
+	}, nil)
+
+	preview, err := h.bot.pdfPreview("F0RECEIPT1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview != "https://files.slack.test/F0RECEIPT1/thumb_720.png" {
+		t.Errorf("expected the largest thumbnail, got %q", preview)
+	}
+}
+
+func TestBotUserLookupIsRetried(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.slack.mu.Lock()
+	h.slack.authErr = errors.New("ratelimited")
+	h.slack.mu.Unlock()
+	if id := h.bot.currentBotUserID(); id != "" {
+		t.Fatalf("expected no bot user while AuthTest fails, got %q", id)
+	}
+
+	h.slack.mu.Lock()
+	h.slack.authErr = nil
+	h.slack.mu.Unlock()
+	if id := h.bot.currentBotUserID(); id != fakeBotUser {
+		t.Errorf("expected %q once AuthTest works again, got %q", fakeBotUser, id)
+	}
+}
+
+func TestPendingReceiptsExpire(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	receipt := Receipt{Store: "Kaufland", Currency: "BGN", Total: 42}
+
+	first := slack.SlashCommand{Command: "/receipt", UserID: "U1", ChannelID: testChannel, TriggerID: "receipt-1"}
+	h.bot.postReceiptConfirmation(first, receipt)
+	h.clock.Advance(pendingReceiptTTL + time.Minute)
+	second := slack.SlashCommand{Command: "/receipt", UserID: "U1", ChannelID: testChannel, TriggerID: "receipt-2"}
+	h.bot.postReceiptConfirmation(second, receipt)
+
+	h.bot.pendingReceiptsMu.Lock()
+	pending := len(h.bot.pendingReceipts)
+	h.bot.pendingReceiptsMu.Unlock()
+	if pending != 1 {
+		t.Fatalf("expected the expired receipt to be dropped, %d are pending", pending)
+	}
+
+	var callback slack.InteractionCallback
+	callback.User.ID = "U1"
+	callback.Channel.ID = testChannel
+	h.bot.handleReceiptAction(callback, receiptConfirmAction, correlationID(first))
+	h.expectMessage("already handled or has expired")
+}
+
+func TestReceiptConfirmAndSplit(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.describer.reply = "```json\n" + `{"store": "Kaufland", "date": "2024-06-07", "currency": "BGN", "items": [
+		{"name": "Kebapche 10 pcs", "quantity": 1, "unit_price": 15, "total": 15},
+		{"name": "Charcoal", "quantity": 1, "unit_price": 6, "total": 6}], "total": 21}` + "\n```"
+
+	h.run(testChef, "/start 12:30")
+	h.run("U1", "/order kebapche 10")
+	h.run("U2", "/order kufte 8")
+
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	h.slack.addFile("U1", slack.File{ID: "F0RECEIPT1", Name: "receipt.png", Mimetype: "image/png", Size: photo.Len()}, photo.Bytes())
+
+	receipt := h.run("U1", "/receipt")
+	h.expectMessage("I read this receipt")
+	if len(h.describer.mimeTypes) != 1 || h.describer.mimeTypes[0] != "image/png" {
+		t.Fatalf("expected the photo to be read once as PNG, got %v", h.describer.mimeTypes)
+	}
+
+	h.click("U2", receiptConfirmAction, correlationID(receipt))
+	h.expectMessage("Only the person who ran /receipt or an admin can confirm it.")
+	h.click("U1", receiptConfirmAction, correlationID(receipt))
+	h.expectMessage("<@U1> paid 21.00 BGN for the session open until 12:30")
+
+	// U1 pays the kebapche alone and half the charcoal, U2 the other half.
+	h.run("U2", "/split")
+	split := h.expectMessage("Cost split for the session")
+	for _, line := range []string{
+		"<@U1>: share 18.00 BGN, paid 21.00 BGN, gets back 3.00 BGN",
+		"<@U2>: share 3.00 BGN, owes 3.00 BGN",
+	} {
+		if !strings.Contains(split.Text, line) {
+			t.Errorf("split %q is missing %q", split.Text, line)
+		}
+	}
+}
+
+func TestAdminConfirmsForThePayer(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.describer.reply = `{"store": "Kaufland", "currency": "BGN", "items": [{"name": "Kebapche 10 pcs", "quantity": 1, "unit_price": 15, "total": 15}], "total": 15}`
+
+	h.run(testChef, "/start 12:30")
+	h.run("U1", "/order kebapche 5")
+	h.run("U2", "/order kebapche 5")
+
+	var photo bytes.Buffer
+	if err := png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	h.slack.addFile("U1", slack.File{ID: "F0RECEIPT2", Name: "receipt.png", Mimetype: "image/png", Size: photo.Len()}, photo.Bytes())
+	receipt := h.run("U1", "/receipt")
+	h.expectMessage("I read this receipt")
+
+	h.click(testChef, receiptConfirmAction, correlationID(receipt))
+	h.expectMessage("<@U1> paid 15.00 BGN for the session open until 12:30")
+
+	h.run("U2", "/split")
+	split := h.expectMessage("Cost split for the session")
+	if !strings.Contains(split.Text, "<@U1>: share 7.50 BGN, paid 15.00 BGN, gets back 7.50 BGN") || strings.Contains(split.Text, "<@"+testChef+">") {
+		t.Errorf("expected U1 to have paid, got %q", split.Text)
+	}
+}
+
+func TestSplitCosts(t *testing.T) {
+	t.Parallel()
+	orders := []SessionOrder{
+		{User: "U1", Item: "kebapche", Quantity: 10},
+		{User: "U2", Item: "kebapche", Quantity: 5},
+		{User: "U2", Item: "kufte", Quantity: 4},
+		{User: "U3", Item: "kufte", Quantity: 4},
+	}
+	// expense is a receipt with the given lines, totalling total.
+	expense := func(total float64, lines ...ReceiptItem) Expense {
+		return Expense{PaidBy: "U1", Receipt: Receipt{Currency: "BGN", Items: lines, Total: total}}
+	}
+
+	tests := []struct {
+		name     string
+		expenses []Expense
+		want     map[string]float64
+	}{
+		{
+			name:     "ordered items by quantity",
+			expenses: []Expense{expense(23, ReceiptItem{Name: "Kebapche 10 pcs", Total: 15}, ReceiptItem{Name: "Kufte", Total: 8})},
+			want:     map[string]float64{"U1": 10, "U2": 9, "U3": 4},
+		},
+		{
+			name:     "other items evenly",
+			expenses: []Expense{expense(6, ReceiptItem{Name: "Charcoal", Total: 6})},
+			want:     map[string]float64{"U1": 2, "U2": 2, "U3": 2},
+		},
+		{
+			name:     "a deposit not in the items evenly",
+			expenses: []Expense{expense(18, ReceiptItem{Name: "Kebapche", Total: 15})},
+			want:     map[string]float64{"U1": 11, "U2": 6, "U3": 1},
+		},
+		{
+			name:     "a misspelt item still matches",
+			expenses: []Expense{expense(8, ReceiptItem{Name: "Kufet grill mix", Total: 8})},
+			want:     map[string]float64{"U1": 0, "U2": 4, "U3": 4},
+		},
+		{
+			name: "several receipts add up",
+			expenses: []Expense{
+				expense(15, ReceiptItem{Name: "Kebapche", Total: 15}),
+				expense(3, ReceiptItem{Name: "Bread", Total: 3}),
+			},
+			want: map[string]float64{"U1": 11, "U2": 6, "U3": 1},
+		},
+		{
+			name:     "shares are rounded to cents",
+			expenses: []Expense{expense(10, ReceiptItem{Name: "Napkins", Total: 10})},
+			want:     map[string]float64{"U1": 3.33, "U2": 3.33, "U3": 3.33},
+		},
+	}
+	for _, test := range tests {
+		shares := splitCosts(SessionRecord{Orders: orders, Expenses: test.expenses})
+		if !reflect.DeepEqual(shares, test.want) {
+			t.Errorf("%s: splitCosts = %v, want %v", test.name, shares, test.want)
+		}
+	}
+}
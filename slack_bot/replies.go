@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// correlationID identifies one slash command in the logs and in the error replies, so a user can quote it.
+// It is derived from the trigger ID so every part of the bot handling the command agrees on it.
+func correlationID(cmd slack.SlashCommand) string {
+	if cmd.TriggerID == "" {
+		buf := make([]byte, 4)
+		if _, err := rand.Read(buf); err != nil {
+			return "unknown"
+		}
+		return hex.EncodeToString(buf)
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(cmd.TriggerID))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
+
+// commandLogger logs with the fields of cmd, so every line about one command can be found by its ID.
+func (b *Bot) commandLogger(cmd slack.SlashCommand) *slog.Logger {
+	logger := slog.With("id", correlationID(cmd), "command", cmd.Command, "user", cmd.UserID, "channel", cmd.ChannelID)
+	if session, ok := b.session.snapshot(); ok {
+		logger = logger.With("session", session.ID)
+	}
+	return logger
+}
+
+// replyEphemeral answers cmd so only the user who sent it sees the reply.
+func (b *Bot) replyEphemeral(cmd slack.SlashCommand, text string) {
+	if _, err := b.poster.PostEphemeral(cmd.ChannelID, cmd.UserID, slack.MsgOptionText(text, false)); err != nil {
+		b.commandLogger(cmd).Error("Failed to post ephemeral message", "err", err)
+	}
+}
+
+// replyError logs a failure while handling cmd and tells the user about it with the correlation ID.
+func (b *Bot) replyError(cmd slack.SlashCommand, text string, err error) {
+	id := correlationID(cmd)
+	markCommandFailed(cmd)
+	if err != nil {
+		b.commandLogger(cmd).Error(text, "err", err)
+	} else {
+		b.commandLogger(cmd).Error(text)
+	}
+	b.replyEphemeral(cmd, fmt.Sprintf("%s Please try again, or quote error ID `%s` when reporting the problem.", text, id))
+}
+
+// closestMatch returns the candidate nearest to value if it is close enough to be a typo.
+func closestMatch(value string, candidates []string) (string, bool) {
+	value = strings.ToLower(value)
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		distance := levenshtein(value, strings.ToLower(candidate))
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	maxDistance := len(value) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	return best, bestDistance >= 0 && bestDistance <= maxDistance
+}
+
+func levenshtein(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	previous := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		current := make([]int, len(br)+1)
+		current[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(br)]
+}
+
+// didYouMean is a " Did you mean `...`?" hint, or empty when nothing is close.
+func didYouMean(value string, candidates []string, format string) string {
+	if match, ok := closestMatch(value, candidates); ok {
+		return fmt.Sprintf(" Did you mean `"+format+"`?", match)
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestDidYouMean(t *testing.T) {
+	t.Parallel()
+	items := []string{"kebapche", "kufte", "Sarmi", "pork chop"}
+	tests := []struct {
+		value      string
+		candidates []string
+		format     string
+		want       string
+	}{
+		{"kebapce", items, "/order %s 2", " Did you mean `/order kebapche 2`?"},
+		{"kufet", items, "/order %s 1", " Did you mean `/order kufte 1`?"},
+		// Case is ignored, the hint keeps the candidate's spelling.
+		{"SARMY", items, "%s", " Did you mean `Sarmi`?"},
+		{"porkchop", items, "%s", " Did you mean `pork chop`?"},
+		{"pizza", items, "%s", ""},
+		{"burger", items, "%s", ""},
+		{"kebapche", nil, "%s", ""},
+		{"/strat", []string{"/start", "/split", "/shopping-list"}, "%s", " Did you mean `/start`?"},
+	}
+	for _, test := range tests {
+		if got := didYouMean(test.value, test.candidates, test.format); got != test.want {
+			t.Errorf("didYouMean(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
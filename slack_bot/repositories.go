@@ -0,0 +1,37 @@
+package main
+
+// MenuRepository looks up and changes the menu items orders are placed for.
+type MenuRepository interface {
+	// Items maps item names to how they are grilled, it is nil when the menu can't be fetched.
+	Items() map[string]ItemInfo
+	// ItemID is the backend ID of the named item, empty when there is no such item.
+	ItemID(name string) string
+	AddItem(item map[string]interface{}) error
+	SetSecondsToCook(name string, seconds int) error
+}
+
+// OrderRepository stores what a closed session ordered.
+type OrderRepository interface {
+	SendOrderSummary(summary map[string]interface{})
+	RecentOrders() []string
+	SendRecentOrders(orders []string)
+	// Summaries returns every order summary sent so far, oldest first.
+	Summaries() ([]OrderSummaryRecord, error)
+}
+
+// GrillRepository reads what the scale recorded and relays its readings.
+type GrillRepository interface {
+	// GrillRecords returns the cooking sessions, sorted by end time.
+	GrillRecords() ([]GrillRecord, error)
+	// BottleWeight is the weight of the empty gas bottle in grams, which the scale's readings include.
+	BottleWeight() (float64, error)
+	GrillActive() (bool, error)
+	ForwardReading(reading GrillReading) error
+	ForwardStatus(reading GrillStatusReading) error
+}
+
+var (
+	_ MenuRepository  = (*bubbleBackend)(nil)
+	_ OrderRepository = (*bubbleBackend)(nil)
+	_ GrillRepository = (*bubbleBackend)(nil)
+)
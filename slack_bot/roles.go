@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Role is what a user is allowed to do with the bot. Every role can do everything the lower ones can.
+type Role string
+
+const (
+	roleMember Role = "member"
+	roleChef   Role = "chef"
+	roleAdmin  Role = "admin"
+
+	defaultRoleStore = "./data/roles.json"
+	defaultAuditLog  = "./data/audit.jsonl"
+	roleUsage        = "Usage: `/role` to see your role, `/role list`, `/role grant @user {admin|chef|member}`, `/role revoke @user`, `/role audit [count]`"
+)
+
+// AuditEntry is one privileged command, allowed or not.
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Action  string    `json:"action"`
+	Role    Role      `json:"required_role"`
+	Allowed bool      `json:"allowed"`
+}
+
+var (
+	roleStoreMu sync.Mutex
+	auditMu     sync.Mutex
+
+	userIDPattern = regexp.MustCompile(`^[UW][A-Z0-9]{8,}$`)
+)
+
+func (r Role) rank() int {
+	switch r {
+	case roleAdmin:
+		return 2
+	case roleChef:
+		return 1
+	}
+	return 0
+}
+
+func parseRole(value string) (Role, bool) {
+	switch role := Role(strings.ToLower(value)); role {
+	case roleAdmin, roleChef, roleMember:
+		return role, true
+	}
+	return "", false
+}
+
+// defaultRole is the role of users without a stored one, DEFAULT_ROLE or member.
+func (b *Bot) defaultRole() Role {
+	// DEFAULT_ROLE was checked when the config was loaded.
+	role, _ := parseRole(b.config().DefaultRole)
+	return role
+}
+
+// userRole returns the stored role of userID. Users in ADMIN_USERS are always admins so the bot can be bootstrapped.
+// When the role store can't be read everyone else is a member, whatever DEFAULT_ROLE says, and the error is returned.
+func (b *Bot) userRole(userID string) (Role, error) {
+	if b.config().isAdminUser(userID) {
+		return roleAdmin, nil
+	}
+
+	roles, err := b.loadRoles()
+	if err != nil {
+		return roleMember, err
+	}
+	if role, ok := roles[userID]; ok {
+		return role, nil
+	}
+	return b.defaultRole(), nil
+}
+
+func (b *Bot) hasRole(userID string, role Role) (bool, error) {
+	userRole, err := b.userRole(userID)
+	return userRole.rank() >= role.rank(), err
+}
+
+// authorize checks the user's role before cmd is dispatched and audits privileged commands.
+func (b *Bot) authorize(cmd slack.SlashCommand, required Role) bool {
+	if required.rank() == roleMember.rank() {
+		return true
+	}
+
+	allowed, err := b.hasRole(cmd.UserID, required)
+	b.audit(cmd.UserID, strings.TrimSpace(cmd.Command+" "+cmd.Text), required, allowed)
+	if err != nil {
+		b.replyError(cmd, fmt.Sprintf("Failed to load the roles, so commands that need the %s role are disabled.", required), err)
+		return false
+	}
+	if !allowed {
+		denied := fmt.Sprintf("You need the %s role to do that. Ask an admin to `/role grant` it to you.", required)
+		if _, err := b.poster.PostEphemeral(cmd.ChannelID, cmd.UserID, slack.MsgOptionText(denied, false)); err != nil {
+			slog.Error("Failed to post ephemeral message", "err", err)
+		}
+	}
+	return allowed
+}
+
+// audit appends a privileged action to the audit log.
+func (b *Bot) audit(userID, action string, required Role, allowed bool) {
+	slog.Info("Audit", "user", userID, "action", action, "role", required, "allowed", allowed)
+
+	data, err := json.Marshal(AuditEntry{Time: b.clock.Now(), User: userID, Action: action, Role: required, Allowed: allowed})
+	if err != nil {
+		slog.Error("Failed to encode audit entry", "err", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	path := b.config().AuditLog
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Error("Failed to write audit log", "err", err)
+		return
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		slog.Error("Failed to write audit log", "err", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		slog.Error("Failed to write audit log", "err", err)
+	}
+}
+
+func (b *Bot) readAuditLog() ([]AuditEntry, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	data, err := ioutil.ReadFile(b.config().AuditLog)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			slog.Warn("Skipping invalid audit entry", "err", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (b *Bot) handleRole(cmd slack.SlashCommand) {
+	args := strings.Fields(cmd.Text)
+	if len(args) == 0 {
+		role, err := b.userRole(cmd.UserID)
+		if err != nil {
+			b.replyError(cmd, "Failed to load the roles.", err)
+			return
+		}
+		postMessage(b.poster, cmd.ChannelID, fmt.Sprintf("<@%s>, your role is %s.", cmd.UserID, role))
+		return
+	}
+
+	var message string
+	var err error
+	switch args[0] {
+	case "list":
+		message, err = b.listRoles()
+	case "grant":
+		if len(args) < 3 {
+			message = roleUsage
+			break
+		}
+		message, err = b.grantRole(cmd.UserID, args[1], args[2])
+	case "revoke":
+		if len(args) < 2 {
+			message = roleUsage
+			break
+		}
+		message, err = b.grantRole(cmd.UserID, args[1], string(roleMember))
+	case "audit":
+		message, err = b.formatAuditLog(args[1:])
+	default:
+		message = roleUsage
+	}
+	if err != nil {
+		b.replyError(cmd, "Failed to update the roles.", err)
+		return
+	}
+	postMessage(b.poster, cmd.ChannelID, message)
+}
+
+func (b *Bot) grantRole(adminID, mention, value string) (string, error) {
+	userID, ok := parseUserMention(mention)
+	if !ok {
+		return "Please mention the user, e.g. `/role grant @alice chef`.", nil
+	}
+	role, ok := parseRole(value)
+	if !ok {
+		return "The role must be admin, chef or member.", nil
+	}
+
+	err := b.updateRoles(func(roles map[string]Role) {
+		if role == b.defaultRole() {
+			delete(roles, userID)
+			return
+		}
+		roles[userID] = role
+	})
+	if err != nil {
+		return "", err
+	}
+	slog.Info("Role changed", "admin", adminID, "user", userID, "role", role)
+	return fmt.Sprintf("<@%s> is now %s.", userID, role), nil
+}
+
+// parseUserMention reads a user ID from an escaped mention (<@U123|name>) or a raw user ID, so that nothing but
+// a well-formed ID ends up in the role store.
+func parseUserMention(value string) (string, bool) {
+	id := value
+	if strings.HasPrefix(value, "<@") && strings.HasSuffix(value, ">") {
+		id, _, _ = strings.Cut(strings.TrimSuffix(strings.TrimPrefix(value, "<@"), ">"), "|")
+	}
+	if !userIDPattern.MatchString(id) {
+		return "", false
+	}
+	return id, true
+}
+
+func (b *Bot) listRoles() (string, error) {
+	roles, err := b.loadRoles()
+	if err != nil {
+		return "", err
+	}
+	for _, admin := range b.config().AdminUsers {
+		roles[admin] = roleAdmin
+	}
+
+	var users []string
+	for user := range roles {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if roles[users[i]] != roles[users[j]] {
+			return roles[users[i]].rank() > roles[users[j]].rank()
+		}
+		return users[i] < users[j]
+	})
+
+	var builder strings.Builder
+	builder.WriteString("Roles :key:\n")
+	for _, user := range users {
+		builder.WriteString(fmt.Sprintf("• <@%s>: %s\n", user, roles[user]))
+	}
+	builder.WriteString(fmt.Sprintf("Everyone else is %s.", b.defaultRole()))
+	return builder.String(), nil
+}
+
+func (b *Bot) formatAuditLog(args []string) (string, error) {
+	count := 10
+	if len(args) > 0 {
+		var err error
+		if count, err = strconv.Atoi(args[0]); err != nil || count <= 0 {
+			return "The count must be a positive number.", nil
+		}
+	}
+
+	entries, err := b.readAuditLog()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "The audit log is empty.", nil
+	}
+	if len(entries) > count {
+		entries = entries[len(entries)-count:]
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Recent privileged actions :scroll:\n")
+	for _, entry := range entries {
+		status := ""
+		if !entry.Allowed {
+			status = " (denied)"
+		}
+		builder.WriteString(fmt.Sprintf("• %s <@%s> `%s`%s\n", entry.Time.Format("2006-01-02 15:04"), entry.User, entry.Action, status))
+	}
+	return builder.String(), nil
+}
+
+func (b *Bot) loadRoles() (map[string]Role, error) {
+	roleStoreMu.Lock()
+	defer roleStoreMu.Unlock()
+	return b.readRoles()
+}
+
+// updateRoles loads the stored roles, applies update and writes the result back.
+func (b *Bot) updateRoles(update func(map[string]Role)) error {
+	roleStoreMu.Lock()
+	defer roleStoreMu.Unlock()
+
+	roles, err := b.readRoles()
+	if err != nil {
+		return err
+	}
+	update(roles)
+
+	data, err := json.MarshalIndent(roles, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := b.config().RoleStore
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (b *Bot) readRoles() (map[string]Role, error) {
+	roles := make(map[string]Role)
+	data, err := ioutil.ReadFile(b.config().RoleStore)
+	if os.IsNotExist(err) {
+		return roles, nil
+	}
+	if err != nil {
+		return roles, err
+	}
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return make(map[string]Role), err
+	}
+	return roles, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseUserMention(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value string
+		id    string
+		ok    bool
+	}{
+		{"<@U0123ABCD|alice>", "U0123ABCD", true},
+		{"<@U0123ABCD>", "U0123ABCD", true},
+		{"<@W0123ABCD|bob>", "W0123ABCD", true},
+		{"U0123ABCD", "U0123ABCD", true},
+		{"W0123ABCD", "W0123ABCD", true},
+		{"<@>", "", false},
+		{"@alice", "", false},
+		{"alice", "", false},
+		{"U0123abcd", "", false},
+		{"U123", "", false},
+		{"<@U123|carol>", "", false},
+		{"<@C0123ABCD>", "", false},
+		{"U0123ABCD\"}", "", false},
+		{"W0123ABCD/../x", "", false},
+	}
+	for _, test := range tests {
+		id, ok := parseUserMention(test.value)
+		if id != test.id || ok != test.ok {
+			t.Errorf("parseUserMention(%q) = %q %v, want %q %v", test.value, id, ok, test.id, test.ok)
+		}
+	}
+}
+
+func TestUnreadableRoleStoreDeniesPrivilegedCommands(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.settings.Load().DefaultRole = string(roleChef)
+	if err := os.WriteFile(h.settings.Load().RoleStore, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// DEFAULT_ROLE would make U1 a chef, but not while the stored roles can't be read.
+	h.run("U1", "/start 12:30")
+	if message := h.expectMessage("Failed to load the roles, so commands that need the chef role are disabled."); !message.Ephemeral {
+		t.Fatalf("expected an ephemeral reply, got %+v", message)
+	}
+	if _, ok := h.bot.session.snapshot(); ok {
+		t.Fatal("the session must not start without the roles")
+	}
+
+	h.run(testChef, "/start 12:30")
+	h.expectMessage("Order session started")
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Schedule is a recurring order session, e.g. "Fri 12:30 deadline 12:00".
+type Schedule struct {
+	ID        int            `json:"id"`
+	ChannelID string         `json:"channel_id"`
+	Rule      string         `json:"rule"`
+	Days      []time.Weekday `json:"days"`
+	Grill     string         `json:"grill"`
+	Deadline  string         `json:"deadline"`
+	Open      string         `json:"open,omitempty"` // empty means SCHEDULE_OPEN_LEAD before the deadline
+	CreatedBy string         `json:"created_by"`
+	Skips     []string       `json:"skips,omitempty"` // dates as 2006-01-02
+	// LastOpened is the date the scheduler last opened (or passed on) this schedule.
+	LastOpened string `json:"last_opened,omitempty"`
+}
+
+type scheduleStore struct {
+	NextID    int        `json:"next_id"`
+	Schedules []Schedule `json:"schedules"`
+	Holidays  []string   `json:"holidays,omitempty"`
+}
+
+const (
+	defaultScheduleStore    = "./data/schedules.json"
+	defaultScheduleOpenLead = 2 * time.Hour
+	scheduleDateLayout      = "2006-01-02"
+	scheduleUsage           = "Usage: `/schedule add \"Fri 12:30 deadline 12:00 [open 10:00]\"`, `/schedule list`, " +
+		"`/schedule remove {id}`, `/schedule skip {id} [YYYY-MM-DD]`, `/schedule holiday add|remove|list [YYYY-MM-DD]`"
+)
+
+var scheduleStoreMu sync.Mutex
+
+func (b *Bot) handleSchedule(cmd slack.SlashCommand) {
+	args := strings.Fields(strings.NewReplacer(`"`, "", "“", "", "”", "").Replace(cmd.Text))
+	if len(args) == 0 {
+		b.replyEphemeral(cmd, scheduleUsage)
+		return
+	}
+
+	var message string
+	var err error
+	switch args[0] {
+	case "add":
+		message, err = b.addSchedule(cmd, args[1:])
+	case "list":
+		message, err = b.listSchedules()
+	case "remove":
+		message, err = b.removeSchedule(args[1:])
+	case "skip":
+		message, err = b.skipSchedule(args[1:], b.localNow())
+	case "holiday":
+		message, err = b.handleHoliday(args[1:])
+	default:
+		message = scheduleUsage
+	}
+	if err != nil {
+		b.replyError(cmd, "Failed to save the schedule.", err)
+		return
+	}
+	postMessage(b.poster, cmd.ChannelID, message)
+}
+
+func (b *Bot) addSchedule(cmd slack.SlashCommand, args []string) (string, error) {
+	schedule, err := parseScheduleRule(args)
+	if err != nil {
+		return err.Error() + "\n" + scheduleUsage, nil
+	}
+	schedule.ChannelID = cmd.ChannelID
+	schedule.CreatedBy = cmd.UserID
+
+	err = b.updateSchedules(func(store *scheduleStore) {
+		store.NextID++
+		schedule.ID = store.NextID
+		store.Schedules = append(store.Schedules, schedule)
+	})
+	if err != nil {
+		return "", err
+	}
+	b.commandLogger(cmd).Info("Schedule added", "schedule", schedule.ID, "rule", schedule.Rule)
+
+	next, ok := schedule.nextOpen(b.localNow(), nil, b.config().ScheduleOpenLead)
+	message := fmt.Sprintf("Schedule %d added: %s.", schedule.ID, schedule.Describe())
+	if ok {
+		message += fmt.Sprintf(" The next session opens %s.", next.Format("Mon 2 Jan 15:04"))
+	}
+	return message, nil
+}
+
+// parseScheduleRule parses "<days> <grill time> deadline <HH:MM> [open <HH:MM>]".
+func parseScheduleRule(args []string) (Schedule, error) {
+	if len(args) < 4 || args[2] != "deadline" {
+		return Schedule{}, fmt.Errorf("Please give the days, grill time and order deadline.")
+	}
+
+	days, ok := parseScheduleDays(args[0])
+	if !ok {
+		return Schedule{}, fmt.Errorf("Invalid days %q, use e.g. Fri, Mon,Wed,Fri, Mon-Fri or daily.", args[0])
+	}
+	schedule := Schedule{Rule: strings.Join(args, " "), Days: days}
+
+	grill, err := time.Parse("15:04", args[1])
+	if err != nil {
+		return Schedule{}, fmt.Errorf("Invalid grill time %q, use HH:MM.", args[1])
+	}
+	deadline, err := time.Parse("15:04", args[3])
+	if err != nil {
+		return Schedule{}, fmt.Errorf("Invalid deadline %q, use HH:MM.", args[3])
+	}
+	if deadline.After(grill) {
+		return Schedule{}, fmt.Errorf("The order deadline must not be after the grill time.")
+	}
+	schedule.Grill = grill.Format("15:04")
+	schedule.Deadline = deadline.Format("15:04")
+
+	if len(args) > 4 {
+		if len(args) != 6 || args[4] != "open" {
+			return Schedule{}, fmt.Errorf("Unexpected %q after the deadline.", strings.Join(args[4:], " "))
+		}
+		open, err := time.Parse("15:04", args[5])
+		if err != nil || !open.Before(deadline) {
+			return Schedule{}, fmt.Errorf("The open time must be HH:MM before the deadline.")
+		}
+		schedule.Open = open.Format("15:04")
+	}
+	return schedule, nil
+}
+
+// parseScheduleDays accepts a day, a comma separated list, a range like Mon-Fri, or daily.
+func parseScheduleDays(value string) ([]time.Weekday, bool) {
+	if value == "daily" || value == "*" {
+		value = "sun-sat"
+	}
+
+	seen := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, ok := parseWeekday(first)
+		if !ok {
+			return nil, false
+		}
+		to := from
+		if isRange {
+			if to, ok = parseWeekday(last); !ok {
+				return nil, false
+			}
+		}
+		for day := from; ; day = (day + 1) % 7 {
+			seen[day] = true
+			if day == to {
+				break
+			}
+		}
+	}
+
+	var days []time.Weekday
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if seen[day] {
+			days = append(days, day)
+		}
+	}
+	return days, len(days) > 0
+}
+
+func (s Schedule) Describe() string {
+	var days []string
+	for _, day := range s.Days {
+		days = append(days, day.String()[:3])
+	}
+	description := fmt.Sprintf("%s, grill at %s, orders until %s", strings.Join(days, ","), s.Grill, s.Deadline)
+	if s.Open != "" {
+		description += ", opens at " + s.Open
+	}
+	return description
+}
+
+func (s Schedule) runsOn(day time.Weekday) bool {
+	for _, d := range s.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// times returns when the session opens and closes on the given date, lead before the deadline when the
+// schedule has no opening time.
+func (s Schedule) times(date time.Time, lead time.Duration) (time.Time, time.Time) {
+	at := func(value string) time.Time {
+		t, _ := time.Parse("15:04", value)
+		return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location())
+	}
+	deadline := at(s.Deadline)
+	if s.Open != "" {
+		return at(s.Open), deadline
+	}
+	return deadline.Add(-lead), deadline
+}
+
+// skipped reports whether the schedule does not run on date because of a skip or a holiday.
+func (s Schedule) skipped(date string, holidays []string) bool {
+	return containsString(s.Skips, date) || containsString(holidays, date)
+}
+
+// nextOpen returns when the schedule next opens a session after now, within the coming weeks.
+func (s Schedule) nextOpen(now time.Time, holidays []string, lead time.Duration) (time.Time, bool) {
+	for i := 0; i < 60; i++ {
+		date := now.AddDate(0, 0, i)
+		key := date.Format(scheduleDateLayout)
+		if !s.runsOn(date.Weekday()) || s.skipped(key, holidays) || s.LastOpened == key {
+			continue
+		}
+		open, deadline := s.times(date, lead)
+		if deadline.After(now) {
+			if open.Before(now) {
+				open = now
+			}
+			return open, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// nextScheduledOpen returns when the next scheduled session opens, false when nothing is scheduled.
+func (b *Bot) nextScheduledOpen() (time.Time, bool) {
+	store, err := b.loadSchedules()
+	if err != nil {
+		slog.Error("Failed to load the schedules", "err", err)
+		return time.Time{}, false
+	}
+	now := b.localNow()
+	var next time.Time
+	found := false
+	for _, schedule := range store.Schedules {
+		open, ok := schedule.nextOpen(now, store.Holidays, b.config().ScheduleOpenLead)
+		if ok && (!found || open.Before(next)) {
+			next, found = open, true
+		}
+	}
+	return next, found
+}
+
+func (b *Bot) listSchedules() (string, error) {
+	store, err := b.loadSchedules()
+	if err != nil {
+		return "", err
+	}
+	if len(store.Schedules) == 0 {
+		return "There are no scheduled sessions. Add one with `/schedule add \"Fri 12:30 deadline 12:00\"`.", nil
+	}
+
+	now := b.localNow()
+	var builder strings.Builder
+	builder.WriteString("Scheduled sessions :calendar:\n")
+	for _, schedule := range store.Schedules {
+		line := fmt.Sprintf("%d. %s in <#%s>", schedule.ID, schedule.Describe(), schedule.ChannelID)
+		if next, ok := schedule.nextOpen(now, store.Holidays, b.config().ScheduleOpenLead); ok {
+			line += ", next " + next.Format("Mon 2 Jan 15:04")
+		}
+		if len(schedule.Skips) > 0 {
+			line += ", skipping " + strings.Join(schedule.Skips, ", ")
+		}
+		builder.WriteString(line + "\n")
+	}
+	if len(store.Holidays) > 0 {
+		builder.WriteString("Holidays: " + strings.Join(store.Holidays, ", ") + "\n")
+	}
+	return builder.String(), nil
+}
+
+func (b *Bot) removeSchedule(args []string) (string, error) {
+	if len(args) < 1 {
+		return "Please specify the schedule: `/schedule remove {id}`", nil
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return "The schedule id must be a number, see `/schedule list`.", nil
+	}
+
+	var removed bool
+	err = b.updateSchedules(func(store *scheduleStore) {
+		for i, schedule := range store.Schedules {
+			if schedule.ID == id {
+				store.Schedules = append(store.Schedules[:i], store.Schedules[i+1:]...)
+				removed = true
+				return
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	if !removed {
+		return fmt.Sprintf("There is no schedule %d.", id), nil
+	}
+	return fmt.Sprintf("Schedule %d removed.", id), nil
+}
+
+// skipSchedule skips the given date, or the next session of the schedule when no date is given.
+func (b *Bot) skipSchedule(args []string, now time.Time) (string, error) {
+	if len(args) < 1 {
+		return "Please specify the schedule: `/schedule skip {id} [YYYY-MM-DD]`", nil
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return "The schedule id must be a number, see `/schedule list`.", nil
+	}
+	var date string
+	if len(args) > 1 {
+		parsed, err := time.ParseInLocation(scheduleDateLayout, args[1], b.config().Location())
+		if err != nil {
+			return "Invalid date, use YYYY-MM-DD.", nil
+		}
+		date = parsed.Format(scheduleDateLayout)
+	}
+
+	var message string
+	err = b.updateSchedules(func(store *scheduleStore) {
+		for i := range store.Schedules {
+			schedule := &store.Schedules[i]
+			if schedule.ID != id {
+				continue
+			}
+			if date == "" {
+				next, ok := schedule.nextOpen(now, store.Holidays, b.config().ScheduleOpenLead)
+				if !ok {
+					message = fmt.Sprintf("Schedule %d has no upcoming session to skip.", id)
+					return
+				}
+				date = next.Format(scheduleDateLayout)
+			}
+			if !containsString(schedule.Skips, date) {
+				schedule.Skips = append(schedule.Skips, date)
+				sort.Strings(schedule.Skips)
+			}
+			message = fmt.Sprintf("Schedule %d will skip %s.", id, date)
+			return
+		}
+		message = fmt.Sprintf("There is no schedule %d.", id)
+	})
+	return message, err
+}
+
+func (b *Bot) handleHoliday(args []string) (string, error) {
+	if len(args) == 0 || args[0] == "list" {
+		store, err := b.loadSchedules()
+		if err != nil {
+			return "", err
+		}
+		if len(store.Holidays) == 0 {
+			return "There are no holidays. Add one with `/schedule holiday add {YYYY-MM-DD}`.", nil
+		}
+		return "Holidays: " + strings.Join(store.Holidays, ", "), nil
+	}
+	if len(args) < 2 || (args[0] != "add" && args[0] != "remove") {
+		return "Usage: `/schedule holiday add|remove {YYYY-MM-DD}` or `/schedule holiday list`", nil
+	}
+	if _, err := time.Parse(scheduleDateLayout, args[1]); err != nil {
+		return "Invalid date, use YYYY-MM-DD.", nil
+	}
+	date := args[1]
+
+	err := b.updateSchedules(func(store *scheduleStore) {
+		var holidays []string
+		for _, holiday := range store.Holidays {
+			if holiday != date {
+				holidays = append(holidays, holiday)
+			}
+		}
+		if args[0] == "add" {
+			holidays = append(holidays, date)
+			sort.Strings(holidays)
+		}
+		store.Holidays = holidays
+	})
+	if err != nil {
+		return "", err
+	}
+	if args[0] == "add" {
+		return fmt.Sprintf("No scheduled sessions will open on %s.", date), nil
+	}
+	return fmt.Sprintf("%s is no longer a holiday.", date), nil
+}
+
+// watchSchedules opens the scheduled sessions once their open time comes.
+func (b *Bot) watchSchedules() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		// While shutting down the schedules are left for the next run, which opens them if still due.
+		b.handleInFlight(func() { b.runSchedules(b.localNow()) })
+		<-ticker.C
+	}
+}
+
+func (b *Bot) runSchedules(now time.Time) {
+	today := now.Format(scheduleDateLayout)
+	var due []Schedule
+	err := b.updateSchedules(func(store *scheduleStore) {
+		for i := range store.Schedules {
+			schedule := &store.Schedules[i]
+			if schedule.LastOpened == today || !schedule.runsOn(now.Weekday()) {
+				continue
+			}
+			if schedule.skipped(today, store.Holidays) {
+				slog.Info("Skipping schedule", "schedule", schedule.ID, "date", today)
+				schedule.LastOpened = today
+				continue
+			}
+			open, deadline := schedule.times(now, b.config().ScheduleOpenLead)
+			if now.Before(open) || !now.Before(deadline) {
+				continue
+			}
+			schedule.LastOpened = today
+			due = append(due, *schedule)
+		}
+		// Skips in the past are no longer needed.
+		for i := range store.Schedules {
+			var skips []string
+			for _, skip := range store.Schedules[i].Skips {
+				if skip >= today {
+					skips = append(skips, skip)
+				}
+			}
+			store.Schedules[i].Skips = skips
+		}
+	})
+	if err != nil {
+		slog.Error("Failed to update schedules", "err", err)
+		return
+	}
+
+	for _, schedule := range due {
+		_, deadline := schedule.times(now, b.config().ScheduleOpenLead)
+		session := b.newSessionRecord(schedule.ChannelID, deadline, "")
+		if !b.session.open(session, PriorityQueue{}, false) {
+			slog.Info("A session is already open, not opening schedule", "schedule", schedule.ID)
+			continue
+		}
+		slog.Info("Opening scheduled session", "schedule", schedule.ID)
+		postMessage(b.poster, schedule.ChannelID, fmt.Sprintf("Scheduled grill session :calendar: The grill starts at %s.", schedule.Grill))
+		b.announceSession(session, b.config().StartRecommendations)
+	}
+}
+
+func (b *Bot) loadSchedules() (scheduleStore, error) {
+	scheduleStoreMu.Lock()
+	defer scheduleStoreMu.Unlock()
+	return b.readSchedules()
+}
+
+// updateSchedules loads the stored schedules, applies update and writes the result back.
+func (b *Bot) updateSchedules(update func(*scheduleStore)) error {
+	scheduleStoreMu.Lock()
+	defer scheduleStoreMu.Unlock()
+
+	store, err := b.readSchedules()
+	if err != nil {
+		return err
+	}
+	update(&store)
+
+	data, err := json.MarshalIndent(store, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := b.config().ScheduleStore
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (b *Bot) readSchedules() (scheduleStore, error) {
+	var store scheduleStore
+	data, err := ioutil.ReadFile(b.config().ScheduleStore)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return store, err
+	}
+	err = json.Unmarshal(data, &store)
+	return store, err
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
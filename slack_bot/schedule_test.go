@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduleKeepsSessionOpenedDuringOrders(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.run(testChef, `/schedule add "Fri 13:00 deadline 12:45 open 11:00"`)
+	h.expectMessage("Schedule 1 added")
+	h.run(testChef, "/start 12:30")
+	started, _ := h.bot.session.snapshot()
+
+	// The scheduler finds the schedule due while people are ordering in the session opened by hand.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.bot.runSchedules(h.clock.Now())
+	}()
+	for i := 0; i < 20; i++ {
+		h.run("U1", "/order kebapche 1")
+	}
+	wg.Wait()
+
+	session, ok := h.bot.session.snapshot()
+	if !ok || session.ID != started.ID || !session.Deadline.Equal(started.Deadline) {
+		t.Fatalf("the scheduler replaced the open session %+v with %+v", started, session)
+	}
+	if len(session.Orders) != 20 {
+		t.Errorf("expected 20 orders, got %d", len(session.Orders))
+	}
+	h.expectNoMessage("Scheduled grill session")
+
+	store, err := h.bot.loadSchedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Schedules[0].LastOpened != "2024-06-07" {
+		t.Errorf("the schedule should be passed on for today, got %+v", store.Schedules[0])
+	}
+}
+
+func TestParseScheduleDays(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value string
+		days  []time.Weekday
+		ok    bool
+	}{
+		{"fri", []time.Weekday{time.Friday}, true},
+		{"Friday", []time.Weekday{time.Friday}, true},
+		{"mon,wed,fri", []time.Weekday{time.Monday, time.Wednesday, time.Friday}, true},
+		{"mon-wed", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday}, true},
+		// A range past Saturday wraps around to Sunday.
+		{"fri-mon", []time.Weekday{time.Sunday, time.Monday, time.Friday, time.Saturday}, true},
+		{"mon-tue,tue", []time.Weekday{time.Monday, time.Tuesday}, true},
+		{"daily", []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}, true},
+		{"funday", nil, false},
+		{"mon-", nil, false},
+		{"", nil, false},
+	}
+	for _, test := range tests {
+		days, ok := parseScheduleDays(test.value)
+		if ok != test.ok || !reflect.DeepEqual(days, test.days) {
+			t.Errorf("parseScheduleDays(%q) = %v %v, want %v %v", test.value, days, ok, test.days, test.ok)
+		}
+	}
+}
+
+func TestScheduleNextOpen(t *testing.T) {
+	t.Parallel()
+	// Friday 7 June 2024, 12:00.
+	now := time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC)
+	friday := Schedule{Days: []time.Weekday{time.Friday}, Deadline: "12:45", Open: "11:00"}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 6, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		now      time.Time
+		holidays []string
+		open     time.Time
+		ok       bool
+	}{
+		{name: "open now until the deadline", schedule: friday, now: now, open: now, ok: true},
+		{name: "before it opens", schedule: friday, now: at(7, 9, 0), open: at(7, 11, 0), ok: true},
+		{name: "after the deadline", schedule: friday, now: at(7, 13, 0), open: at(14, 11, 0), ok: true},
+		{name: "opened today already", schedule: Schedule{Days: friday.Days, Deadline: "12:45", Open: "11:00", LastOpened: "2024-06-07"},
+			now: now, open: at(14, 11, 0), ok: true},
+		{name: "skipped", schedule: Schedule{Days: friday.Days, Deadline: "12:45", Open: "11:00", Skips: []string{"2024-06-07"}},
+			now: now, open: at(14, 11, 0), ok: true},
+		{name: "holiday", schedule: friday, now: at(7, 9, 0), holidays: []string{"2024-06-07", "2024-06-14"}, open: at(21, 11, 0), ok: true},
+		// Without an opening time it opens the lead before the deadline.
+		{name: "lead", schedule: Schedule{Days: []time.Weekday{time.Monday}, Deadline: "12:00"}, now: now, open: at(10, 10, 0), ok: true},
+		{name: "no days", schedule: Schedule{Deadline: "12:00"}, now: now},
+	}
+	for _, test := range tests {
+		open, ok := test.schedule.nextOpen(test.now, test.holidays, 2*time.Hour)
+		if ok != test.ok || !open.Equal(test.open) {
+			t.Errorf("%s: nextOpen = %v %v, want %v %v", test.name, open, ok, test.open, test.ok)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionStartOrderSummary(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.orders.recent = []string{"order-1", "order-2"}
+
+	h.run("U1", "/order kebapche 2")
+	if message := h.expectMessage("Orders are not enabled"); !message.Ephemeral || message.User != "U1" {
+		t.Fatalf("expected an ephemeral reply to U1, got %+v", message)
+	}
+
+	h.run(testChef, "/start 12:30")
+	h.expectMessage("Order session started <!here> . You can place orders until 12:30.")
+
+	h.run("U1", "/order kebapche 12")
+	h.run("U2", "/order kufte 3")
+	h.run("U2", "/order kebapche 2")
+	h.expectMessage("Order placed: kebapche 12")
+	h.expectMessage("Order placed: kufte 3")
+
+	h.run("U3", "/order pizza 1")
+	if message := h.expectMessage("pizza is not on the menu"); !message.Ephemeral {
+		t.Fatalf("expected an ephemeral reply, got %+v", message)
+	}
+
+	h.advance(24 * time.Minute)
+	h.expectNoMessage("left to place your orders")
+	h.clock.Advance(time.Minute)
+	h.expectMessage("<!here> 5 minutes left to place your orders.")
+	h.expectNoMessage("You have collectively ordered")
+
+	h.advance(5 * time.Minute)
+	summary := h.expectMessage("You have collectively ordered")
+	// The rest goes to the backend after the summary is posted, shutdown waits for it the same way.
+	h.bot.inFlight.Wait()
+	for _, line := range []string{"kebapche x14", "kufte x3"} {
+		if !strings.Contains(summary.Text, line) {
+			t.Errorf("summary %q is missing %q", summary.Text, line)
+		}
+	}
+
+	if len(h.orders.summaries) != 2 {
+		t.Fatalf("expected a summary per item, got %+v", h.orders.summaries)
+	}
+	for _, itemSummary := range h.orders.summaries {
+		if id := itemSummary["item ordered"]; id != "id-kebapche" && id != "id-kufte" {
+			t.Errorf("unexpected item ID %v", id)
+		}
+	}
+	if len(h.orders.sent) != 1 || len(h.orders.sent[0]) != 2 {
+		t.Errorf("expected the recent orders to be sent once, got %+v", h.orders.sent)
+	}
+
+	sessions, err := h.bot.loadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("expected one stored session, got %d", len(sessions))
+	}
+	session := sessions[0]
+	if session.StartedBy != testChef || len(session.Orders) != 3 || !session.Closed.Equal(h.clock.Now()) {
+		t.Errorf("unexpected stored session %+v", session)
+	}
+
+	h.run("U1", "/order kufte 1")
+	if _, ok := h.bot.session.snapshot(); ok {
+		t.Fatal("the session should be closed after the deadline")
+	}
+}
+
+func TestSessionWithoutOrders(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.run(testChef, "/start 12:10")
+	h.advance(5 * time.Minute)
+	h.expectMessage("5 minutes left")
+	h.advance(5 * time.Minute)
+	h.expectMessage("No orders were placed.")
+	h.bot.inFlight.Wait()
+
+	if len(h.orders.summaries) != 0 || len(h.orders.sent) != 0 {
+		t.Errorf("nothing should be sent to the backend, got %+v and %+v", h.orders.summaries, h.orders.sent)
+	}
+}
+
+func TestStartNeedsChefRole(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.run("U1", "/start 12:30")
+	if message := h.expectMessage("You need the chef role"); !message.Ephemeral {
+		t.Fatalf("expected an ephemeral reply, got %+v", message)
+	}
+	if _, ok := h.bot.session.snapshot(); ok {
+		t.Fatal("a member must not start a session")
+	}
+
+	entries, err := h.bot.readAuditLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Allowed || entries[0].Action != "/start 12:30" {
+		t.Errorf("expected the denied /start to be audited, got %+v", entries)
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.run(testChef, "/start noon")
+	h.expectMessage("Usage: `/start {time} [suggest]`")
+	h.run(testChef, "/start 12:30")
+	h.run("U1", "/order kebapche lots")
+	h.expectMessage("Usage: `/order {item} {quantity}`")
+	h.run("U1", "/strat 12:30")
+	h.expectMessage("I don't know /strat. Did you mean `/start`?")
+
+	if session, _ := h.bot.session.snapshot(); len(session.Orders) != 0 {
+		t.Errorf("invalid orders must not be queued, got %+v", session.Orders)
+	}
+}
+
+func TestResumeAfterRestart(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	// The session is opened without its deadline watcher, as if the bot was stopped with it open.
+	h.bot.session.open(h.bot.newSessionRecord(testChannel, h.clock.Now().Add(30*time.Minute), testChef), PriorityQueue{}, true)
+	h.run("U1", "/order kufte 4")
+	h.expectMessage("Order placed: kufte 4")
+	if err := h.bot.persistOpenSession(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next run starts after the deadline passed.
+	h.restart()
+	h.clock.Advance(time.Hour)
+	h.bot.resumeOpenSession()
+
+	h.expectMessage("I was restarting when the order deadline passed")
+	h.expectMessage("kufte x4")
+	if len(h.orders.summaries) != 1 {
+		t.Errorf("expected the resumed orders to be summarized, got %+v", h.orders.summaries)
+	}
+}
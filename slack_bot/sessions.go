@@ -0,0 +1,288 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SessionRecord is a closed order session kept locally so later features can learn from past sessions.
+type SessionRecord struct {
+	ID        string                     `json:"id"`
+	ChannelID string                     `json:"channel_id"`
+	StartedBy string                     `json:"started_by"`
+	Chef      string                     `json:"chef"`
+	Started   time.Time                  `json:"started"`
+	Deadline  time.Time                  `json:"deadline"`
+	Closed    time.Time                  `json:"closed"`
+	Orders    []SessionOrder             `json:"orders"`
+	Beers     *int                       `json:"beers,omitempty"`
+	Ratings   map[string]int             `json:"ratings,omitempty"` // chef rating (1-5) by participant
+	Feedback  map[string]SessionFeedback `json:"feedback,omitempty"`
+	Expenses  []Expense                  `json:"expenses,omitempty"`
+	// PlannedSeconds is the cook time per item estimated from seconds to cook when the session closed.
+	PlannedSeconds map[string]int `json:"planned_seconds,omitempty"`
+}
+
+type SessionOrder struct {
+	User     string `json:"user"`
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+const (
+	defaultSessionStore = "./data/sessions.json"
+	// A grill record belongs to a session if the grill was lit within this long after the order deadline.
+	sessionGrillWindow = 3 * time.Hour
+)
+
+var sessionStoreMu sync.Mutex
+
+// sessionID names a session after the time it started.
+func sessionID(started time.Time) string {
+	return started.Format("20060102-150405")
+}
+
+// orderSession is the open order session. Commands, the deadline watcher, the scheduler and the telemetry
+// server all use it from their own goroutines, so it is only read and changed under mu.
+type orderSession struct {
+	mu      sync.Mutex
+	enabled bool
+	queue   PriorityQueue
+	// record has everything but the orders, which are kept in queue.
+	record SessionRecord
+}
+
+// newSessionRecord describes a session opened now. startedBy is empty for scheduled sessions.
+func (b *Bot) newSessionRecord(channelID string, deadline time.Time, startedBy string) SessionRecord {
+	started := b.clock.Now()
+	return SessionRecord{
+		ID:        sessionID(started),
+		ChannelID: channelID,
+		StartedBy: startedBy,
+		Chef:      startedBy,
+		Started:   started,
+		Deadline:  deadline,
+	}
+}
+
+// open makes session the open one with queue as its orders. An open session is only replaced when
+// replace is set, otherwise open returns false.
+func (s *orderSession) open(session SessionRecord, queue PriorityQueue, replace bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enabled && !replace {
+		return false
+	}
+	session.Orders = nil
+	s.enabled = true
+	s.queue = queue
+	s.record = session
+	s.updateMetricsLocked()
+	return true
+}
+
+// snapshot returns a copy of the open session with its orders so far, false when no session is open.
+func (s *orderSession) snapshot() (SessionRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return SessionRecord{}, false
+	}
+	return s.recordLocked(), true
+}
+
+func (s *orderSession) recordLocked() SessionRecord {
+	session := s.record
+	session.Expenses = append([]Expense(nil), s.record.Expenses...)
+	for _, order := range s.queue {
+		session.Orders = append(session.Orders, SessionOrder{User: order.User, Item: order.Item, Quantity: order.Quantity})
+	}
+	return session
+}
+
+// isOpen reports whether the session that started at started is still the open one.
+func (s *orderSession) isOpen(started time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled && s.record.Started.Equal(started)
+}
+
+// addOrder queues order, it returns false when the session closed in the meantime.
+func (s *orderSession) addOrder(order *Order) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return false
+	}
+	heap.Push(&s.queue, order)
+	s.updateMetricsLocked()
+	return true
+}
+
+// setChef records who is on the grill, false when no session is open.
+func (s *orderSession) setChef(userID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return false
+	}
+	s.record.Chef = userID
+	return true
+}
+
+// addExpense adds expense to the open session and returns its deadline, false when no session is open.
+func (s *orderSession) addExpense(expense Expense) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return time.Time{}, false
+	}
+	s.record.Expenses = append(s.record.Expenses, expense)
+	return s.record.Deadline, true
+}
+
+// close ends the session that started at started and returns it with its orders. It returns false if that
+// session is no longer open, because it was closed already or replaced by a newer one.
+func (s *orderSession) close(started time.Time) (SessionRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled || !s.record.Started.Equal(started) {
+		return SessionRecord{}, false
+	}
+	session := s.recordLocked()
+	s.enabled = false
+	s.queue = nil
+	s.updateMetricsLocked()
+	return session, true
+}
+
+func (s *orderSession) updateMetricsLocked() {
+	if s.enabled {
+		activeSessions.Set(1)
+	} else {
+		activeSessions.Set(0)
+	}
+	openSessionOrders.Set(float64(len(s.queue)))
+}
+
+// recordClosedSession stores session as it closes now.
+func (b *Bot) recordClosedSession(session SessionRecord, itemData map[string]ItemInfo) SessionRecord {
+	session.Closed = b.clock.Now()
+	session.PlannedSeconds = plannedCookSeconds(session, itemData)
+	sessionOrders.Observe(float64(len(session.Orders)))
+
+	err := b.updateSessions(func(sessions []SessionRecord) []SessionRecord {
+		return append(sessions, session)
+	})
+	if err != nil {
+		slog.Error("Failed to store session", "err", err)
+	}
+	return session
+}
+
+func (b *Bot) loadSessions() ([]SessionRecord, error) {
+	sessionStoreMu.Lock()
+	defer sessionStoreMu.Unlock()
+	return b.readSessions()
+}
+
+// updateSessions loads the stored sessions, applies update and writes the result back.
+func (b *Bot) updateSessions(update func([]SessionRecord) []SessionRecord) error {
+	sessionStoreMu.Lock()
+	defer sessionStoreMu.Unlock()
+
+	sessions, err := b.readSessions()
+	if err != nil {
+		return err
+	}
+	sessions = update(sessions)
+
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := b.config().SessionStore
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash can't leave half a file behind.
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (b *Bot) readSessions() ([]SessionRecord, error) {
+	data, err := ioutil.ReadFile(b.config().SessionStore)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []SessionRecord
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Participants returns the distinct users who ordered in the session.
+func (s SessionRecord) Participants() []string {
+	seen := make(map[string]bool)
+	var users []string
+	for _, order := range s.Orders {
+		if order.User != "" && !seen[order.User] {
+			seen[order.User] = true
+			users = append(users, order.User)
+		}
+	}
+	return users
+}
+
+func (s SessionRecord) ItemQuantities() map[string]int {
+	quantities := make(map[string]int)
+	for _, order := range s.Orders {
+		quantities[order.Item] += order.Quantity
+	}
+	return quantities
+}
+
+func (s SessionRecord) TotalQuantity() int {
+	total := 0
+	for _, order := range s.Orders {
+		total += order.Quantity
+	}
+	return total
+}
+
+// plannedCookSeconds estimates the cook time of each item in the session the same way summarizeOrders does.
+func plannedCookSeconds(session SessionRecord, itemData map[string]ItemInfo) map[string]int {
+	planned := make(map[string]int)
+	for item, quantity := range session.ItemQuantities() {
+		itemInfo, ok := itemData[item]
+		if !ok || itemInfo.CapacityOnGrill <= 0 {
+			continue
+		}
+		planned[item] = calculateCookingTime(quantity, itemInfo.CapacityOnGrill, itemInfo.SecondsToCook)
+	}
+	return planned
+}
+
+// grillRecordForSession finds the grill session that cooked the orders of session, if the scale recorded one.
+func grillRecordForSession(session SessionRecord, records []GrillRecord) (GrillRecord, bool) {
+	for _, record := range records {
+		if !record.Start.Before(session.Started) && record.Start.Before(session.Deadline.Add(sessionGrillWindow)) {
+			return record, true
+		}
+	}
+	return GrillRecord{}, false
+}
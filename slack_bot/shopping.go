@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	recommendationSessions = 5
+	defaultShoppingMargin  = 10.0 // percent
+	defaultStoreSection    = "Other"
+	// A closed session's orders are still "current" for shopping until this long after it closed.
+	shoppingListWindow = 12 * time.Hour
+	// Order summaries created within this long of each other belong to the same session.
+	summarySessionGap = time.Hour
+)
+
+// postShoppingRecommendation suggests quantities for the new session from the last few sessions.
+func (b *Bot) postShoppingRecommendation(channelID string) {
+	message, err := b.shoppingRecommendation()
+	if err != nil {
+		slog.Error("Failed to build shopping recommendation", "err", err)
+		return
+	}
+	if message != "" {
+		postMessage(b.poster, channelID, message)
+	}
+}
+
+func (b *Bot) shoppingRecommendation() (string, error) {
+	sessions, err := b.loadSessions()
+	if err != nil {
+		return "", err
+	}
+
+	if recent := recentSessions(sessions); len(recent) > 0 {
+		return perPersonRecommendation(recent), nil
+	}
+
+	summaries, err := b.orders.Summaries()
+	if err != nil {
+		return "", err
+	}
+	return perSessionRecommendation(summaries), nil
+}
+
+// recentSessions returns the last few sessions with orders, newest first. Per person averages need
+// participants, which only the local session history has.
+func recentSessions(sessions []SessionRecord) []SessionRecord {
+	var recent []SessionRecord
+	for i := len(sessions) - 1; i >= 0 && len(recent) < recommendationSessions; i-- {
+		if len(sessions[i].Participants()) > 0 {
+			recent = append(recent, sessions[i])
+		}
+	}
+	return recent
+}
+
+// sessionTotals adds up what the sessions ordered and how many people ordered.
+func sessionTotals(sessions []SessionRecord) (map[string]int, int) {
+	totals := make(map[string]int)
+	people := 0
+	for _, session := range sessions {
+		people += len(session.Participants())
+		for item, quantity := range session.ItemQuantities() {
+			totals[item] += quantity
+		}
+	}
+	return totals, people
+}
+
+// usualQuantities is what the average number of people orders of each item, going by their orders per
+// person in sessions.
+func usualQuantities(sessions []SessionRecord) map[string]int {
+	totals, people := sessionTotals(sessions)
+	if people == 0 {
+		return nil
+	}
+	averagePeople := float64(people) / float64(len(sessions))
+	quantities := make(map[string]int, len(totals))
+	for item, total := range totals {
+		quantities[item] = int(math.Ceil(float64(total) / float64(people) * averagePeople))
+	}
+	return quantities
+}
+
+func perPersonRecommendation(sessions []SessionRecord) string {
+	totals, people := sessionTotals(sessions)
+	averagePeople := float64(people) / float64(len(sessions))
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Shopping suggestion :shopping_trolley: The last %d sessions averaged %.1f people and per person:\n",
+		len(sessions), averagePeople))
+	for _, total := range sortedTotals(totals) {
+		perPerson := float64(total.Quantity) / float64(people)
+		builder.WriteString(fmt.Sprintf("• %.1f %s (about %.0f for %.0f people)\n",
+			perPerson, total.Item, math.Ceil(perPerson*averagePeople), averagePeople))
+	}
+	return builder.String()
+}
+
+// perSessionRecommendation groups Bubble order summaries into sessions when there is no local history.
+func perSessionRecommendation(summaries []OrderSummaryRecord) string {
+	if len(summaries) == 0 {
+		return ""
+	}
+
+	var groups [][]OrderSummaryRecord
+	for i := len(summaries) - 1; i >= 0; i-- {
+		summary := summaries[i]
+		last := len(groups) - 1
+		if last >= 0 && groups[last][0].Created.Sub(summary.Created) < summarySessionGap {
+			groups[last] = append(groups[last], summary)
+			continue
+		}
+		if len(groups) == recommendationSessions {
+			break
+		}
+		groups = append(groups, []OrderSummaryRecord{summary})
+	}
+
+	totals := make(map[string]int)
+	for _, group := range groups {
+		for _, summary := range group {
+			totals[summary.Item] += summary.Quantity
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Shopping suggestion :shopping_trolley: The last %d sessions averaged:\n", len(groups)))
+	for _, total := range sortedTotals(totals) {
+		builder.WriteString(fmt.Sprintf("• %.1f %s per session\n", float64(total.Quantity)/float64(len(groups)), total.Item))
+	}
+	return builder.String()
+}
+
+func (b *Bot) handleShoppingList(cmd slack.SlashCommand) {
+	quantities, ok := b.currentSessionQuantities()
+	if !ok {
+		b.replyEphemeral(cmd, "There is no current session to make a shopping list for. Start one with /start {time}.")
+		return
+	}
+
+	itemData := b.menu.Items()
+	if itemData == nil {
+		b.replyError(cmd, "Failed to fetch item data.", nil)
+		return
+	}
+
+	postMessage(b.poster, cmd.ChannelID, formatShoppingList(quantities, itemData, b.config().ShoppingMargin))
+}
+
+// currentSessionQuantities returns the open session's orders, or the last session's if it closed recently.
+func (b *Bot) currentSessionQuantities() (map[string]int, bool) {
+	if session, ok := b.session.snapshot(); ok && len(session.Orders) > 0 {
+		return session.ItemQuantities(), true
+	}
+
+	sessions, err := b.loadSessions()
+	if err != nil {
+		slog.Error("Failed to load sessions", "err", err)
+		return nil, false
+	}
+	if len(sessions) == 0 {
+		return nil, false
+	}
+	last := sessions[len(sessions)-1]
+	if b.clock.Now().Sub(last.Closed) > shoppingListWindow {
+		return nil, false
+	}
+	return last.ItemQuantities(), true
+}
+
+func formatShoppingList(quantities map[string]int, itemData map[string]ItemInfo, margin float64) string {
+	sections := make(map[string]map[string]int)
+	for item, quantity := range quantities {
+		section := itemData[item].StoreSection
+		if section == "" {
+			section = defaultStoreSection
+		}
+		if sections[section] == nil {
+			sections[section] = make(map[string]int)
+		}
+		sections[section][item] = int(math.Ceil(float64(quantity) * (1 + margin/100)))
+	}
+
+	var names []string
+	for section := range sections {
+		names = append(names, section)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Shopping list (orders + %.0f%% margin):\n", margin))
+	for _, section := range names {
+		builder.WriteString("*" + section + "*\n")
+		for _, total := range sortedTotals(sections[section]) {
+			builder.WriteString(fmt.Sprintf("• %s x%d\n", total.Item, total.Quantity))
+		}
+	}
+	return builder.String()
+}
+
+func sortedTotals(quantities map[string]int) []ItemTotal {
+	var totals []ItemTotal
+	for item, quantity := range quantities {
+		totals = append(totals, ItemTotal{Item: item, Quantity: quantity})
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Quantity != totals[j].Quantity {
+			return totals[i].Quantity > totals[j].Quantity
+		}
+		return totals[i].Item < totals[j].Item
+	})
+	return totals
+}
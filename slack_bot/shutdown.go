@@ -0,0 +1,162 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout  = 30 * time.Second
+	defaultOpenSessionStore = "./data/open_session.json"
+)
+
+// handleInFlight runs handler unless the bot is shutting down, and makes shutdown wait for it.
+func (b *Bot) handleInFlight(handler func()) bool {
+	if !b.beginInFlight() {
+		return false
+	}
+	defer b.inFlight.Done()
+	handler()
+	return true
+}
+
+// goInFlight is handleInFlight for work that runs in its own goroutine.
+func (b *Bot) goInFlight(work func()) bool {
+	if !b.beginInFlight() {
+		return false
+	}
+	go func() {
+		defer b.inFlight.Done()
+		work()
+	}()
+	return true
+}
+
+// beginInFlight counts one more piece of work for shutdown to wait for, false when it is too late.
+func (b *Bot) beginInFlight() bool {
+	b.inFlightMu.Lock()
+	defer b.inFlightMu.Unlock()
+	if b.shuttingDown.Load() {
+		return false
+	}
+	b.inFlight.Add(1)
+	return true
+}
+
+// shutdown stops taking commands, waits for the ones being handled and their backend writes, stores the
+// open session for the next run and stops the HTTP servers, all within SHUTDOWN_TIMEOUT. The socket mode
+// connection is closed by the caller afterwards so the last replies still go out.
+func (b *Bot) shutdown(servers ...*http.Server) {
+	timeout := b.config().ShutdownTimeout
+	slog.Info("Shutting down", "timeout", timeout)
+	b.inFlightMu.Lock()
+	b.shuttingDown.Store(true)
+	b.inFlightMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Gave up waiting for commands in flight")
+	}
+
+	if err := b.persistOpenSession(); err != nil {
+		slog.Error("Failed to store the open session", "err", err)
+	}
+
+	for _, server := range servers {
+		if server == nil {
+			continue
+		}
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Failed to stop HTTP server", "addr", server.Addr, "err", err)
+		}
+	}
+}
+
+// persistOpenSession writes the open session to OPEN_SESSION_STORE so resumeOpenSession can pick it up.
+func (b *Bot) persistOpenSession() error {
+	session, ok := b.session.snapshot()
+	if !ok {
+		return nil
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := b.config().OpenSessionStore
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	slog.Info("Stored the open session for the next start", "session", session.ID, "orders", len(session.Orders))
+	return nil
+}
+
+// resumeOpenSession reopens the session a previous run handed off. If its deadline passed while the bot
+// was down, the orders are summarized right away.
+func (b *Bot) resumeOpenSession() {
+	path := b.config().OpenSessionStore
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to read the open session", "err", err)
+		return
+	}
+	// It is only resumed once, even if this run fails.
+	if err := os.Remove(path); err != nil {
+		slog.Error("Failed to remove the open session", "err", err)
+	}
+
+	var session SessionRecord
+	if err := json.Unmarshal(data, &session); err != nil {
+		slog.Error("Failed to parse the open session", "err", err)
+		return
+	}
+
+	itemData := b.menu.Items()
+	queue := PriorityQueue{}
+	for _, order := range session.Orders {
+		cookTime := 0
+		if itemInfo, ok := itemData[order.Item]; ok {
+			cookTime = calculateCookingTime(order.Quantity, itemInfo.CapacityOnGrill, itemInfo.SecondsToCook)
+		}
+		queue = append(queue, &Order{User: order.User, Item: order.Item, Quantity: order.Quantity, CookTime: cookTime})
+	}
+	heap.Init(&queue)
+	b.session.open(session, queue, true)
+	slog.Info("Resumed the open session", "session", session.ID, "orders", len(session.Orders), "deadline", session.Deadline)
+
+	if !b.clock.Now().Before(session.Deadline) {
+		postMessage(b.poster, session.ChannelID, "I was restarting when the order deadline passed, here are the orders.")
+		b.handleInFlight(func() { b.summarizeOrders(session.Started) })
+		return
+	}
+	postMessage(b.poster, session.ChannelID, fmt.Sprintf("I'm back after a restart. The session is still open, you can place orders until %s.",
+		session.Deadline.In(b.config().Location()).Format("15:04")))
+	go b.watchDeadline(session)
+}
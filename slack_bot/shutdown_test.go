@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForWorkInFlight(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go h.bot.handleInFlight(func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		h.bot.shutdown()
+		close(done)
+	}()
+	h.eventually("shutdown to start", h.bot.shuttingDown.Load)
+	if h.bot.handleInFlight(func() {}) {
+		t.Fatal("work was accepted while shutting down")
+	}
+	select {
+	case <-done:
+		t.Fatal("shutdown returned before the work in flight finished")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-done
+}
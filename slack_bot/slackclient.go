@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+
+	"github.com/slack-go/slack"
+)
+
+// MessagePoster posts and edits messages, which is all most handlers need from Slack.
+type MessagePoster interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	PostEphemeral(channelID, userID string, options ...slack.MsgOption) (string, error)
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+}
+
+// FileClient finds, downloads and uploads files for the receipts and the history exports. AuthTest tells
+// which uploads are the bot's own.
+type FileClient interface {
+	AuthTest() (*slack.AuthTestResponse, error)
+	GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error)
+	GetConversationReplies(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error)
+	GetFile(downloadURL string, writer io.Writer) error
+	GetFileInfo(fileID string, count, page int) (*slack.File, []slack.Comment, *slack.Paging, error)
+	UploadFile(params slack.FileUploadParameters) (*slack.File, error)
+}
+
+// DialogOpener opens the DMs and modals of the feedback surveys.
+type DialogOpener interface {
+	OpenConversation(params *slack.OpenConversationParameters) (*slack.Channel, bool, bool, error)
+	OpenView(triggerID string, view slack.ModalViewRequest) (*slack.ViewResponse, error)
+}
+
+// SlackClient is everything the bot uses from the Slack web API. *slack.Client implements it, tests use a fake.
+type SlackClient interface {
+	MessagePoster
+	FileClient
+	DialogOpener
+}
+
+var _ SlackClient = (*slack.Client)(nil)
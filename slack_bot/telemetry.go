@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GrillReading is the payload the scale posts when a cooking session ends.
+type GrillReading struct {
+	StartGas           float64 `json:"grill start gas"`
+	AverageConsumption float64 `json:"average_consumption"`
+	EndGas             float64 `json:"grill end gas"`
+	StartTime          string  `json:"start time"`
+	EndTime            string  `json:"end time"`
+}
+
+// GrillStatusReading is the payload the scale posts when cooking starts ("yes") or stops ("no").
+type GrillStatusReading struct {
+	Status string `json:"status"`
+}
+
+type storedReading struct {
+	ReceivedAt time.Time       `json:"received_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+const (
+	defaultTelemetryDir = "./data"
+	maxTelemetryBody    = 64 << 10
+	grillReadingsFile   = "grill.jsonl"
+	statusReadingsFile  = "grill_status.jsonl"
+)
+
+var telemetryMu sync.Mutex
+
+// startTelemetryServer accepts scale readings directly when TELEMETRY_ADDR is set, stores them
+// locally and relays them to Bubble if the Bubble endpoints are configured. It returns nil when it is off.
+func (b *Bot) startTelemetryServer() *http.Server {
+	addr := b.config().TelemetryAddr
+	if addr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/grill", b.requireBearer(b.handleGrillReading))
+	mux.HandleFunc("/grill/status", b.requireBearer(func(w http.ResponseWriter, r *http.Request) {
+		b.handleGrillStatusReading(w, r)
+	}))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		slog.Info("Telemetry server listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Telemetry server stopped", "err", err)
+		}
+	}()
+	return server
+}
+
+func (b *Bot) requireBearer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+b.config().TelemetryToken {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (b *Bot) handleGrillReading(w http.ResponseWriter, r *http.Request) {
+	var reading GrillReading
+	if err := decodeTelemetry(w, r, &reading); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := parseBubbleTime(reading.StartTime); err != nil {
+		http.Error(w, "invalid start time", http.StatusBadRequest)
+		return
+	}
+	if _, err := parseBubbleTime(reading.EndTime); err != nil {
+		http.Error(w, "invalid end time", http.StatusBadRequest)
+		return
+	}
+
+	if err := b.storeReading(grillReadingsFile, reading); err != nil {
+		slog.Error("Failed to store grill reading", "err", err)
+		http.Error(w, "failed to store reading", http.StatusInternalServerError)
+		return
+	}
+
+	if err := b.grill.ForwardReading(reading); err != nil {
+		slog.Error("Failed to forward grill reading", "err", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (b *Bot) handleGrillStatusReading(w http.ResponseWriter, r *http.Request) {
+	var reading GrillStatusReading
+	if err := decodeTelemetry(w, r, &reading); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reading.Status != "yes" && reading.Status != "no" {
+		http.Error(w, `status must be "yes" or "no"`, http.StatusBadRequest)
+		return
+	}
+
+	if err := b.storeReading(statusReadingsFile, reading); err != nil {
+		slog.Error("Failed to store grill status", "err", err)
+		http.Error(w, "failed to store reading", http.StatusInternalServerError)
+		return
+	}
+
+	if err := b.grill.ForwardStatus(reading); err != nil {
+		slog.Error("Failed to forward grill status", "err", err)
+	}
+
+	if channelID := b.config().ChannelID; channelID != "" {
+		if !b.goInFlight(func() { b.handleGrillStatusChange(channelID, reading.Status == "yes") }) {
+			slog.Info("Not announcing the grill status while shutting down", "status", reading.Status)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func decodeTelemetry(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxTelemetryBody))
+	if err != nil {
+		return fmt.Errorf("error reading body: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return nil
+}
+
+func (b *Bot) storeReading(fileName string, reading interface{}) error {
+	data, err := json.Marshal(reading)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(storedReading{ReceivedAt: b.clock.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+
+	telemetryMu.Lock()
+	defer telemetryMu.Unlock()
+
+	if err := os.MkdirAll(b.config().TelemetryDir, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(b.config().TelemetryDir, fileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// loadLocalGrillRecords reads the grill readings the telemetry server stored, sorted by end time.
+func loadLocalGrillRecords(cfg *Config) ([]GrillRecord, error) {
+	telemetryMu.Lock()
+	defer telemetryMu.Unlock()
+
+	file, err := os.Open(filepath.Join(cfg.TelemetryDir, grillReadingsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []GrillRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var stored storedReading
+		var reading GrillReading
+		if err := json.Unmarshal([]byte(line), &stored); err != nil {
+			slog.Warn("Skipping malformed grill reading", "err", err)
+			continue
+		}
+		if err := json.Unmarshal(stored.Data, &reading); err != nil {
+			slog.Warn("Skipping malformed grill reading", "err", err)
+			continue
+		}
+
+		start, err := parseBubbleTime(reading.StartTime)
+		if err != nil {
+			continue
+		}
+		end, err := parseBubbleTime(reading.EndTime)
+		if err != nil {
+			continue
+		}
+		records = append(records, GrillRecord{
+			StartGas:           reading.StartGas,
+			EndGas:             reading.EndGas,
+			AverageConsumption: reading.AverageConsumption,
+			Start:              start,
+			End:                end,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].End.Before(records[j].End) })
+	return records, nil
+}
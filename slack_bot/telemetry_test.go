@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGrillStatusDuringOrders(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.run(testChef, "/start 12:30")
+	h.run("U1", "/order kebapche 2")
+	h.expectMessage("Order placed: kebapche 2")
+
+	// The scale reports the grill on while orders keep coming in.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			h.run("U2", "/order kufte 1")
+		}
+	}()
+	recorder := httptest.NewRecorder()
+	h.bot.handleGrillStatusReading(recorder, httptest.NewRequest(http.MethodPost, "/grill/status", strings.NewReader(`{"status": "yes"}`)))
+	wg.Wait()
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body)
+	}
+	message := h.expectMessage("Grill is on")
+	if !strings.Contains(message.Text, "open until 12:30") || !strings.Contains(message.Text, "orders so far") {
+		t.Errorf("expected the open session in %q", message.Text)
+	}
+}
+
+func TestScaleTimesAreUTC(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	sofia, err := time.LoadLocation("Europe/Sofia")
+	if err != nil {
+		t.Skip("no timezone data:", err)
+	}
+	h.settings.Load().Timezone = "Europe/Sofia"
+	h.settings.Load().location = sofia
+
+	post := func(handler http.HandlerFunc, path, body string) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if recorder.Code >= 300 {
+			t.Fatalf("%s: got %d: %s", path, recorder.Code, recorder.Body)
+		}
+	}
+
+	// The grill runs from 12:00 to 12:40 UTC, 15:00 to 15:40 in Sofia, and the scale reports UTC.
+	post(h.bot.handleGrillStatusReading, "/grill/status", `{"status": "yes"}`)
+	h.expectMessage("Grill is on")
+	h.clock.Advance(40 * time.Minute)
+	post(h.bot.handleGrillReading, "/grill", `{"grill start gas": 11000, "grill end gas": 10500, "start time": "2024-06-07 12:00:00", "end time": "2024-06-07 12:40:00"}`)
+
+	records, err := loadLocalGrillRecords(h.settings.Load())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !records[0].Start.Equal(time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC)) || !records[0].End.Equal(h.clock.Now()) {
+		t.Fatalf("expected the reading in UTC, got %+v", records)
+	}
+	h.grill.records = records
+
+	post(h.bot.handleGrillStatusReading, "/grill/status", `{"status": "no"}`)
+	h.expectMessage("Grill is off — used 0.5 kg in 40 min.")
+}
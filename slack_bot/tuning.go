@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// CookTimeProposal is a suggested seconds to cook for one item, learned from the grill timings.
+type CookTimeProposal struct {
+	Item          string
+	Current       int
+	Proposed      int
+	Sessions      int
+	Ratio         float64 // fitted actual / planned grill time of the item
+	DonenessBias  float64
+	DonenessVotes int
+}
+
+const (
+	tuneApplyAction = "tune_apply"
+	// An item needs this many sessions with a grill record before its time is tuned.
+	minTuningSessions = 2
+	minTuningRatio    = 0.5
+	maxTuningRatio    = 2.0
+	// Changes smaller than this share of the current time aren't worth proposing.
+	minTuningChange = 0.05
+	// tuningRidge pulls each item's ratio towards 1, relative to how much planned time the item has, so
+	// items that were always grilled together still get a ratio.
+	tuningRidge = 0.1
+)
+
+func (b *Bot) handleTune(cmd slack.SlashCommand) {
+	args := strings.Fields(cmd.Text)
+	proposals, err := b.buildCookTimeProposals()
+	if err != nil {
+		b.replyError(cmd, "Failed to compare the planned and actual cooking times.", err)
+		return
+	}
+
+	// handleSlashCommand only lets admins through to /tune apply.
+	if len(args) > 0 && args[0] == "apply" {
+		postMessage(b.poster, cmd.ChannelID, b.applyCookTimeProposals(proposals, cmd.UserID))
+		return
+	}
+
+	if len(proposals) == 0 {
+		postMessage(b.poster, cmd.ChannelID, "The cooking times match the grill timings, nothing to tune.")
+		return
+	}
+
+	text := formatCookTimeProposals(proposals)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(tuneApplyAction, encodeProposals(proposals),
+				slack.NewTextBlockObject(slack.PlainTextType, "Apply (admins only)", false, false))),
+	}
+	if _, _, err := b.poster.PostMessage(cmd.ChannelID, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...)); err != nil {
+		slog.Error("Failed to post message", "err", err)
+	}
+}
+
+// handleTuneApply applies the proposals from a /tune message once an admin approves them.
+func (b *Bot) handleTuneApply(callback slack.InteractionCallback, value string) {
+	allowed, err := b.hasRole(callback.User.ID, roleAdmin)
+	b.audit(callback.User.ID, "tune apply button: "+value, roleAdmin, allowed)
+	if !allowed {
+		text := "Only admins can change the cooking times."
+		if err != nil {
+			slog.Error("Failed to load roles", "err", err)
+			text = "Failed to load the roles, so the cooking times can't be changed. Please try again later."
+		}
+		if _, err := b.poster.PostEphemeral(callback.Channel.ID, callback.User.ID, slack.MsgOptionText(text, false)); err != nil {
+			slog.Error("Failed to post ephemeral message", "err", err)
+		}
+		return
+	}
+
+	postMessage(b.poster, callback.Channel.ID, b.applyCookTimeProposals(decodeProposals(value), callback.User.ID))
+}
+
+func (b *Bot) buildCookTimeProposals() ([]CookTimeProposal, error) {
+	sessions, err := b.loadSessions()
+	if err != nil {
+		return nil, err
+	}
+	records, err := b.grill.GrillRecords()
+	if err != nil {
+		return nil, err
+	}
+	itemData := b.menu.Items()
+	if itemData == nil {
+		return nil, fmt.Errorf("failed to fetch item data")
+	}
+	return cookTimeProposals(sessions, records, itemData), nil
+}
+
+// tuningSample is one session with a grill record: the planned seconds per item and how long the grill ran.
+type tuningSample struct {
+	planned map[string]int
+	actual  float64
+}
+
+// cookTimeProposals fits a ratio per item so that the planned seconds of every session's items, each
+// scaled by its ratio, add up to how long the grill actually ran, and scales each item's seconds to cook
+// by its own ratio. Doneness feedback then nudges the result further.
+func cookTimeProposals(sessions []SessionRecord, records []GrillRecord, itemData map[string]ItemInfo) []CookTimeProposal {
+	var samples []tuningSample
+	counts := make(map[string]int)
+
+	for _, session := range sessions {
+		record, ok := grillRecordForSession(session, records)
+		if !ok {
+			continue
+		}
+		planned := session.PlannedSeconds
+		if len(planned) == 0 {
+			planned = plannedCookSeconds(session, itemData)
+		}
+		total := 0
+		for _, seconds := range planned {
+			total += seconds
+		}
+		actual := record.End.Sub(record.Start).Seconds()
+		if total <= 0 || actual <= 0 {
+			continue
+		}
+
+		samples = append(samples, tuningSample{planned: planned, actual: actual})
+		for item := range planned {
+			counts[item]++
+		}
+	}
+	ratios := fitItemRatios(samples)
+
+	feedback := make(map[string]ItemFeedbackStats)
+	for _, stats := range itemFeedbackStats(sessions, time.Time{}) {
+		feedback[stats.Item] = stats
+	}
+
+	var proposals []CookTimeProposal
+	for item, count := range counts {
+		current := itemData[item].SecondsToCook
+		fitted, ok := ratios[item]
+		if count < minTuningSessions || !ok || current <= 0 {
+			continue
+		}
+
+		ratio := math.Min(math.Max(fitted, minTuningRatio), maxTuningRatio)
+		proposal := CookTimeProposal{Item: item, Current: current, Sessions: count, Ratio: ratio}
+		proposed := float64(current) * ratio
+		if stats, ok := feedback[item]; ok && stats.DonenessVotes() >= minDonenessVotes {
+			proposal.DonenessBias = stats.DonenessBias()
+			proposal.DonenessVotes = stats.DonenessVotes()
+			proposed *= 1 + 0.25*proposal.DonenessBias
+		}
+		proposal.Proposed = int(math.Round(proposed))
+
+		if math.Abs(float64(proposal.Proposed-current)) < minTuningChange*float64(current) {
+			continue
+		}
+		proposals = append(proposals, proposal)
+	}
+
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].Item < proposals[j].Item })
+	return proposals
+}
+
+// fitItemRatios solves the least squares fit of the grill time against the planned seconds per item, with
+// a ridge towards a ratio of 1. Items without planned seconds get no ratio.
+func fitItemRatios(samples []tuningSample) map[string]float64 {
+	index := make(map[string]int)
+	var items []string
+	for _, sample := range samples {
+		for item, seconds := range sample.planned {
+			if _, ok := index[item]; !ok && seconds > 0 {
+				index[item] = len(items)
+				items = append(items, item)
+			}
+		}
+	}
+	n := len(items)
+	if n == 0 {
+		return nil
+	}
+
+	matrix := make([][]float64, n)
+	for i := range matrix {
+		matrix[i] = make([]float64, n+1)
+	}
+	for _, sample := range samples {
+		for a, secondsA := range sample.planned {
+			i, ok := index[a]
+			if !ok {
+				continue
+			}
+			for c, secondsC := range sample.planned {
+				if j, ok := index[c]; ok {
+					matrix[i][j] += float64(secondsA) * float64(secondsC)
+				}
+			}
+			matrix[i][n] += float64(secondsA) * sample.actual
+		}
+	}
+	for i := range items {
+		ridge := tuningRidge * matrix[i][i]
+		matrix[i][i] += ridge
+		matrix[i][n] += ridge
+	}
+
+	solution, ok := solveLinearSystem(matrix)
+	if !ok {
+		return nil
+	}
+	ratios := make(map[string]float64, n)
+	for i, item := range items {
+		ratios[item] = solution[i]
+	}
+	return ratios
+}
+
+func formatCookTimeProposals(proposals []CookTimeProposal) string {
+	var builder strings.Builder
+	builder.WriteString("Proposed cooking times based on the grill timings :stopwatch:\n")
+	for _, proposal := range proposals {
+		line := fmt.Sprintf("• %s: %ds → *%ds* (%d sessions, took %.0f%% of its planned time",
+			proposal.Item, proposal.Current, proposal.Proposed, proposal.Sessions, proposal.Ratio*100)
+		if proposal.DonenessVotes > 0 {
+			line += fmt.Sprintf(", doneness feedback %+.0f%% from %d votes", proposal.DonenessBias*100, proposal.DonenessVotes)
+		}
+		builder.WriteString(line + ")\n")
+	}
+	builder.WriteString("An admin can apply them with the button or `/tune apply`.")
+	return builder.String()
+}
+
+func (b *Bot) applyCookTimeProposals(proposals []CookTimeProposal, userID string) string {
+	if len(proposals) == 0 {
+		return "There are no cooking times to update."
+	}
+
+	var applied, failed []string
+	for _, proposal := range proposals {
+		if err := b.menu.SetSecondsToCook(proposal.Item, proposal.Proposed); err != nil {
+			slog.Error("Failed to update seconds to cook", "item", proposal.Item, "err", err)
+			failed = append(failed, proposal.Item)
+			continue
+		}
+		slog.Info("Seconds to cook changed", "user", userID, "item", proposal.Item, "seconds", proposal.Proposed)
+		applied = append(applied, fmt.Sprintf("%s %ds", proposal.Item, proposal.Proposed))
+	}
+
+	message := fmt.Sprintf("<@%s> updated the cooking times: %s.", userID, strings.Join(applied, ", "))
+	if len(applied) == 0 {
+		message = "No cooking times were updated."
+	}
+	if len(failed) > 0 {
+		message += " Failed to update: " + strings.Join(failed, ", ") + "."
+	}
+	return message
+}
+
+// encodeProposals packs the proposals into a button value as "item=seconds" pairs.
+func encodeProposals(proposals []CookTimeProposal) string {
+	var pairs []string
+	for _, proposal := range proposals {
+		pairs = append(pairs, proposal.Item+"="+strconv.Itoa(proposal.Proposed))
+	}
+	return strings.Join(pairs, ";")
+}
+
+func decodeProposals(value string) []CookTimeProposal {
+	var proposals []CookTimeProposal
+	for _, pair := range strings.Split(value, ";") {
+		item, seconds, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		proposed, err := strconv.Atoi(seconds)
+		if err != nil || proposed <= 0 {
+			continue
+		}
+		proposals = append(proposals, CookTimeProposal{Item: item, Proposed: proposed})
+	}
+	return proposals
+}
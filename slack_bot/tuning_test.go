@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCookTimeProposalsFitEachItem(t *testing.T) {
+	t.Parallel()
+	itemData := map[string]ItemInfo{
+		"kebapche": {ItemName: "kebapche", SecondsToCook: 600, CapacityOnGrill: 10},
+		"kufte":    {ItemName: "kufte", SecondsToCook: 480, CapacityOnGrill: 8},
+	}
+	// Kebapche takes twice as long as planned, kufte as long as planned.
+	tests := []struct {
+		planned map[string]int
+		actual  time.Duration
+	}{
+		{map[string]int{"kebapche": 600, "kufte": 480}, 1680 * time.Second},
+		{map[string]int{"kebapche": 1200}, 2400 * time.Second},
+		{map[string]int{"kufte": 960}, 960 * time.Second},
+		{map[string]int{"kebapche": 600, "kufte": 960}, 2160 * time.Second},
+	}
+	var sessions []SessionRecord
+	var records []GrillRecord
+	day := time.Date(2024, 6, 7, 12, 0, 0, 0, time.UTC)
+	for i, test := range tests {
+		started := day.AddDate(0, 0, 7*i)
+		sessions = append(sessions, SessionRecord{Started: started, Deadline: started.Add(30 * time.Minute), PlannedSeconds: test.planned})
+		records = append(records, GrillRecord{Start: started.Add(time.Hour), End: started.Add(time.Hour + test.actual)})
+	}
+
+	proposals := cookTimeProposals(sessions, records, itemData)
+	if len(proposals) != 1 || proposals[0].Item != "kebapche" {
+		t.Fatalf("expected only kebapche to be tuned, got %+v", proposals)
+	}
+	if proposal := proposals[0]; proposal.Sessions != 3 || proposal.Proposed < 1100 || proposal.Proposed > 1200 {
+		t.Errorf("expected kebapche close to 1200s from 3 sessions, got %+v", proposal)
+	}
+}